@@ -0,0 +1,211 @@
+// rankeval replays logged ranked-feed snapshots against rankers offline.
+//
+// Every candidate of a logged snapshot (at most one per viewer every ten minutes) is
+// stored in feed_impressions with the features it was scored on; a candidate counts as relevant when the viewer liked it within
+// -window of the snapshot (interactions table). Each ranker re-orders the logged
+// candidates and is reported with NDCG@k, recall@k and MRR.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"minifeed/internal/model"
+	"minifeed/internal/service"
+)
+
+type snapshot struct {
+	userID   uint
+	at       time.Time
+	rows     []model.FeedImpression
+	relevant map[uint]bool
+}
+
+type result struct {
+	ndcg, recall, mrr float64
+}
+
+func main() {
+	since := flag.Duration("since", 7*24*time.Hour, "evaluate snapshots taken within this period")
+	window := flag.Duration("window", 24*time.Hour, "a like within this long after the snapshot counts as relevant")
+	k := flag.Int("k", 10, "cutoff for NDCG@k and recall@k")
+	halfLife := flag.Duration("half-life", service.DefaultRanker().HalfLife, "weighted ranker: recency half-life")
+	likeWeight := flag.Float64("like-weight", service.DefaultRanker().LikeWeight, "weighted ranker: engagement weight")
+	affinityWeight := flag.Float64("affinity-weight", service.DefaultRanker().AffinityWeight, "weighted ranker: affinity weight")
+	hotPenalty := flag.Float64("hot-penalty", service.DefaultRanker().HotPenalty, "weighted ranker: multiplier for hot-list candidates")
+	flag.Parse()
+
+	dsn := os.Getenv("MYSQL_DSN")
+	if dsn == "" {
+		log.Fatal("Missing required environment variable MYSQL_DSN")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		log.Fatalf("connect mysql err: %v", err)
+	}
+
+	snapshots, err := loadSnapshots(db, time.Now().Add(-*since), *window)
+	if err != nil {
+		log.Fatalf("load snapshots err: %v", err)
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("no snapshot with a liked candidate in range")
+		return
+	}
+
+	rankers := []struct {
+		name string
+		r    service.Ranker
+	}{
+		{"chrono", service.ChronoRanker{}},
+		{"weighted", service.WeightedRanker{
+			HalfLife:       *halfLife,
+			LikeWeight:     *likeWeight,
+			AffinityWeight: *affinityWeight,
+			HotPenalty:     *hotPenalty,
+		}},
+	}
+
+	fmt.Printf("%d snapshots with at least one like, k=%d\n\n", len(snapshots), *k)
+	fmt.Printf("%-10s %10s %10s %10s\n", "ranker", "ndcg@k", "recall@k", "mrr")
+
+	report := func(name string, order func(s snapshot) []uint) {
+		var sum result
+		for _, s := range snapshots {
+			r := evaluate(order(s), s.relevant, *k)
+			sum.ndcg += r.ndcg
+			sum.recall += r.recall
+			sum.mrr += r.mrr
+		}
+		n := float64(len(snapshots))
+		fmt.Printf("%-10s %10.4f %10.4f %10.4f\n", name, sum.ndcg/n, sum.recall/n, sum.mrr/n)
+	}
+
+	// the order that was actually served
+	report("logged", func(s snapshot) []uint {
+		ids := make([]uint, len(s.rows))
+		for i, row := range s.rows {
+			ids[i] = row.PostID
+		}
+		return ids
+	})
+	for _, rk := range rankers {
+		report(rk.name, func(s snapshot) []uint { return rerank(s, rk.r) })
+	}
+}
+
+// snapshots since the given time whose viewer liked at least one candidate
+func loadSnapshots(db *gorm.DB, since time.Time, window time.Duration) ([]snapshot, error) {
+	var rows []model.FeedImpression
+	if err := db.Where("created_at >= ?", since).Order("snapshot_id, position").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*snapshot)
+	var order []string
+	for _, row := range rows {
+		s, ok := byID[row.SnapshotID]
+		if !ok {
+			s = &snapshot{userID: row.UserID, at: row.CreatedAt, relevant: make(map[uint]bool)}
+			byID[row.SnapshotID] = s
+			order = append(order, row.SnapshotID)
+		}
+		s.rows = append(s.rows, row)
+	}
+
+	var likes []model.Interaction
+	if err := db.Where("kind = ? AND created_at >= ?", "like", since).Find(&likes).Error; err != nil {
+		return nil, err
+	}
+	likedAt := make(map[[2]uint][]time.Time)
+	for _, l := range likes {
+		key := [2]uint{l.UserID, l.PostID}
+		likedAt[key] = append(likedAt[key], l.CreatedAt)
+	}
+
+	snapshots := make([]snapshot, 0, len(order))
+	for _, id := range order {
+		s := byID[id]
+		for _, row := range s.rows {
+			for _, t := range likedAt[[2]uint{s.userID, row.PostID}] {
+				if !t.Before(s.at) && t.Sub(s.at) <= window {
+					s.relevant[row.PostID] = true
+				}
+			}
+		}
+		if len(s.relevant) > 0 {
+			snapshots = append(snapshots, *s)
+		}
+	}
+	return snapshots, nil
+}
+
+// re-scores a snapshot's candidates from their logged features
+func rerank(s snapshot, r service.Ranker) []uint {
+	type scored struct {
+		id    uint
+		score float64
+	}
+	list := make([]scored, len(s.rows))
+	for i, row := range s.rows {
+		c := service.RankCandidate{
+			PostID:    row.PostID,
+			AuthorID:  row.AuthorID,
+			Likes:     row.Likes,
+			Affinity:  row.Affinity,
+			FromHot:   row.FromHot,
+			CreatedAt: s.at.Add(-time.Duration(row.AgeHours * float64(time.Hour))),
+		}
+		list[i] = scored{id: row.PostID, score: r.Score(c, s.at)}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].score != list[j].score {
+			return list[i].score > list[j].score
+		}
+		return list[i].id > list[j].id
+	})
+
+	ids := make([]uint, len(list))
+	for i, l := range list {
+		ids[i] = l.id
+	}
+	return ids
+}
+
+func evaluate(ranked []uint, relevant map[uint]bool, k int) result {
+	var r result
+	var dcg float64
+	hits := 0
+	for i, id := range ranked {
+		if !relevant[id] {
+			continue
+		}
+		if r.mrr == 0 {
+			r.mrr = 1 / float64(i+1)
+		}
+		if i < k {
+			dcg += 1 / math.Log2(float64(i+2))
+			hits++
+		}
+	}
+
+	var idcg float64
+	for i := 0; i < len(relevant) && i < k; i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+	if idcg > 0 {
+		r.ndcg = dcg / idcg
+	}
+	r.recall = float64(hits) / float64(len(relevant))
+	return r
+}
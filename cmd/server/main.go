@@ -3,6 +3,9 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"minifeed/internal/api"
 	"minifeed/internal/config"
@@ -14,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -21,6 +25,13 @@ func main() {
 	mysqlDSN := os.Getenv("MYSQL_DSN")
 	redisAddr := os.Getenv("REDIS_ADDR")
 	jwtSecret := os.Getenv("JWT_SECRET")
+	bloomBackend := os.Getenv("BLOOM_BACKEND")        // "memory" (default) or "redis"
+	inboxMaxLen := os.Getenv("INBOX_MAX_LEN")         // posts kept per push inbox
+	inboxTTL := os.Getenv("INBOX_TTL")                // e.g. "168h": inboxes unread for this long expire
+	searchBackend := os.Getenv("SEARCH_BACKEND")      // "mysql" (default) or "bleve"
+	searchIndexPath := os.Getenv("SEARCH_INDEX_PATH") // bleve index directory, in memory when empty
+	adminIDs := os.Getenv("ADMIN_IDS")                // comma-separated user ids granted the admin role at startup
+	bannedTermsFile := os.Getenv("BANNED_TERMS_FILE") // one term per line, new posts containing one are rejected
 
 	if mysqlDSN == "" || redisAddr == "" || jwtSecret == "" {
 		log.Fatal("Missing required environment variables")
@@ -29,18 +40,40 @@ func main() {
 	db := config.InitDB(mysqlDSN)
 	rdb := config.InitRedis(redisAddr)
 
-	if err := dao.InitPostBloom(db, 10000); err != nil {
-		log.Printf("[warn] init post bloom failed: %v\n", err)
+	if err := dao.InitBlooms(db, bloomBackend); err != nil {
+		log.Printf("[warn] init bloom filters failed: %v\n", err)
+	}
+
+	if err := dao.InitSearch(db, searchBackend, searchIndexPath); err != nil {
+		log.Fatalf("init search index err: %v", err)
 	}
 
 	metrics.Init()
 
+	configureInbox(inboxMaxLen, inboxTTL)
+	configureBannedTerms(bannedTermsFile)
+	bootstrapAdmins(db, adminIDs)
+
+	dao.StartCacheInvalidation()
+
+	middleware.SetStatusCheck(func(userID uint) (middleware.AccountStatus, error) {
+		status, err := dao.GetUserStatus(db, userID)
+		return middleware.AccountStatus{Suspended: status.Suspended, Role: status.Role}, err
+	})
+
 	cron.StartLikeSync(db)
 	cron.StartHotPostsRefresh(db)
+	cron.StartBloomRebuild(db, bloomBackend == dao.BloomBackendRedis)
+	cron.StartSuggestionRefresh(db)
+	cron.StartSearchCatchUp(db)
 
 	userSvc := service.NewUserService(db)
 	postSvc := service.NewPostService(db, rdb)
-	followSvc := service.NewFollowService(db)
+	followSvc := service.NewFollowService(db, rdb)
+	blockSvc := service.NewBlockService(db, rdb, followSvc)
+	listSvc := service.NewListService(db)
+	modSvc := service.NewModerationService(db, rdb)
+	adminSvc := service.NewAdminService(db, rdb)
 
 	r := gin.Default()
 	r.Use(middleware.CORS(), middleware.RequestTiming(), middleware.PrometheusMiddleware())
@@ -48,8 +81,77 @@ func main() {
 	api.UserRoutes(r, userSvc)
 	api.PostRoutes(r, postSvc)
 	api.FollowRoutes(r, followSvc)
+	api.BlockRoutes(r, blockSvc)
+	api.ListRoutes(r, listSvc)
+	api.ModerationRoutes(r, modSvc)
+	api.AdminRoutes(r, adminSvc)
 
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	r.Run(":8888")
 }
+
+// invalid or empty values keep the defaults
+func configureInbox(maxLenStr, ttlStr string) {
+	var maxLen int
+	if maxLenStr != "" {
+		n, err := strconv.Atoi(maxLenStr)
+		if err != nil || n <= 0 {
+			log.Printf("[warn] invalid INBOX_MAX_LEN %q, using %d\n", maxLenStr, service.DefaultInboxMaxLen)
+		} else {
+			maxLen = n
+		}
+	}
+
+	var ttl time.Duration
+	if ttlStr != "" {
+		d, err := time.ParseDuration(ttlStr)
+		if err != nil || d <= 0 {
+			log.Printf("[warn] invalid INBOX_TTL %q, using %s\n", ttlStr, service.DefaultInboxTTL)
+		} else {
+			ttl = d
+		}
+	}
+
+	service.ConfigureInbox(maxLen, ttl)
+}
+
+// invalid ids are skipped
+func bootstrapAdmins(db *gorm.DB, idsStr string) {
+	var ids []uint
+	for _, part := range strings.Split(idsStr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || id == 0 {
+			log.Printf("[warn] invalid user id %q in ADMIN_IDS\n", part)
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	if err := service.BootstrapAdmins(db, ids); err != nil {
+		log.Printf("[warn] grant admin role failed: %v\n", err)
+	}
+}
+
+// an unreadable terms file leaves posts unchecked
+func configureBannedTerms(termsFile string) {
+	if termsFile == "" {
+		return
+	}
+	// a set but unreadable file would silently turn moderation off, so refuse to start
+	data, err := os.ReadFile(termsFile)
+	if err != nil {
+		log.Fatalf("read BANNED_TERMS_FILE err: %v", err)
+	}
+	var terms []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	log.Printf("loaded %d banned terms\n", service.SetBannedTerms(terms))
+}
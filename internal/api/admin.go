@@ -0,0 +1,224 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"minifeed/internal/middleware"
+	"minifeed/internal/model"
+	"minifeed/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func AdminRoutes(r *gin.Engine, adminSvc *service.AdminService) {
+	adminGroup := r.Group("/admin", middleware.Auth(), middleware.RequireRole(model.RoleAdmin))
+
+	//=================== suspend / unsuspend an user ===================
+	adminGroup.POST("/users/:id/suspend", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9101)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		if err := adminSvc.SuspendUser(adminID, userID, req.Reason); err != nil {
+			adminFail(c, 9101, err)
+			return
+		}
+		OK(c, gin.H{"msg": "user suspended", "user_id": userID})
+	})
+
+	adminGroup.DELETE("/users/:id/suspend", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9111)
+		if !ok {
+			return
+		}
+
+		if err := adminSvc.UnsuspendUser(adminID, userID); err != nil {
+			adminFail(c, 9111, err)
+			return
+		}
+		OK(c, gin.H{"msg": "user unsuspended", "user_id": userID})
+	})
+
+	//=================== shadow ban / lift it ===================
+	adminGroup.POST("/users/:id/shadowban", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9181)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		if err := adminSvc.ShadowBan(adminID, userID, req.Reason); err != nil {
+			adminFail(c, 9181, err)
+			return
+		}
+		OK(c, gin.H{"msg": "user shadow banned", "user_id": userID})
+	})
+
+	adminGroup.DELETE("/users/:id/shadowban", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9191)
+		if !ok {
+			return
+		}
+
+		if err := adminSvc.UnshadowBan(adminID, userID); err != nil {
+			adminFail(c, 9191, err)
+			return
+		}
+		OK(c, gin.H{"msg": "shadow ban lifted", "user_id": userID})
+	})
+
+	//=================== change an user's role ===================
+	adminGroup.PUT("/users/:id/role", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9121)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Fail(c, 9124, "invalid request!")
+			return
+		}
+
+		if err := adminSvc.SetRole(adminID, userID, req.Role); err != nil {
+			adminFail(c, 9121, err)
+			return
+		}
+		OK(c, gin.H{"msg": "role updated", "user_id": userID, "role": req.Role})
+	})
+
+	//=================== inspect an user's push inbox ===================
+	adminGroup.GET("/users/:id/inbox", func(c *gin.Context) {
+		adminID, userID, ok := adminTarget(c, 9131)
+		if !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		snap, err := adminSvc.InspectInbox(adminID, userID, limit)
+		if err != nil {
+			adminFail(c, 9131, err)
+			return
+		}
+		OK(c, snap)
+	})
+
+	//=================== remove a post ===================
+	adminGroup.DELETE("/posts/:id", func(c *gin.Context) {
+		adminID, postID, ok := adminTarget(c, 9141)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = c.ShouldBindJSON(&req)
+
+		if err := adminSvc.RemovePost(adminID, postID, req.Reason); err != nil {
+			adminFail(c, 9141, err)
+			return
+		}
+		OK(c, gin.H{"msg": "post removed", "post_id": postID})
+	})
+
+	//=================== reset a post's likes ===================
+	adminGroup.POST("/posts/:id/reset-likes", func(c *gin.Context) {
+		adminID, postID, ok := adminTarget(c, 9151)
+		if !ok {
+			return
+		}
+
+		if err := adminSvc.ResetLikes(adminID, postID); err != nil {
+			adminFail(c, 9151, err)
+			return
+		}
+		OK(c, gin.H{"msg": "likes reset", "post_id": postID})
+	})
+
+	//=================== rebuild the hot rankings and cache ===================
+	adminGroup.POST("/hot/rebuild", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 9161, "no user in context")
+			return
+		}
+		adminID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 9162, "invalid user id")
+			return
+		}
+
+		if err := adminSvc.RebuildHotCache(adminID); err != nil {
+			Fail(c, 9163, "db or cache error")
+			return
+		}
+		OK(c, gin.H{"msg": "hot cache rebuilt"})
+	})
+
+	//=================== audit log ===================
+	adminGroup.GET("/audit", func(c *gin.Context) {
+		actorID, _ := strconv.ParseUint(c.Query("actor_id"), 10, 64)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+
+		entries, nextCursor, err := adminSvc.ListAudit(uint(actorID), limit, cursor)
+		if err != nil {
+			Fail(c, 9171, "db error")
+			return
+		}
+		OK(c, gin.H{
+			"list":        entries,
+			"next_cursor": nextCursor,
+		})
+	})
+}
+
+// the admin and the user or post in :id; codes are base+0 .. base+2
+func adminTarget(c *gin.Context, base int) (uint, uint, bool) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return 0, 0, false
+	}
+	adminID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return 0, 0, false
+	}
+
+	targetID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || targetID64 == 0 {
+		Fail(c, base+2, "invalid target id")
+		return 0, 0, false
+	}
+	return adminID, uint(targetID64), true
+}
+
+// maps an admin action's error to base+4 .. base+7; base+3 is a malformed body
+func adminFail(c *gin.Context, base int, err error) {
+	switch {
+	case errors.Is(err, service.ErrAdminSelf), errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidReason):
+		Fail(c, base+4, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		Fail(c, base+5, "user not found")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		Fail(c, base+6, "post not found")
+	default:
+		Fail(c, base+7, "db or cache error")
+	}
+}
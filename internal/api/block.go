@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"minifeed/internal/middleware"
+	"minifeed/internal/service"
+
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func BlockRoutes(r *gin.Engine, blockSvc *service.BlockService) {
+	authGroup := r.Group("/api", middleware.Auth())
+
+	//=================== block / unblock an user ===================
+	authGroup.POST("/block/:id", func(c *gin.Context) {
+		changeRelation(c, 7001, "block succeeded", blockSvc.Block)
+	})
+
+	authGroup.POST("/unblock/:id", func(c *gin.Context) {
+		changeRelation(c, 7011, "unblock succeeded", blockSvc.Unblock)
+	})
+
+	//=================== mute / unmute an user ===================
+	authGroup.POST("/mute/:id", func(c *gin.Context) {
+		changeRelation(c, 7021, "mute succeeded", blockSvc.Mute)
+	})
+
+	authGroup.POST("/unmute/:id", func(c *gin.Context) {
+		changeRelation(c, 7031, "unmute succeeded", blockSvc.Unmute)
+	})
+
+	//=================== users I blocked / muted ===================
+	authGroup.GET("/blocks", func(c *gin.Context) {
+		listUserPage(c, 7041, blockSvc.ListBlocked)
+	})
+
+	authGroup.GET("/mutes", func(c *gin.Context) {
+		listUserPage(c, 7051, blockSvc.ListMuted)
+	})
+}
+
+// applies a relation change from me to the user in :id; codes are base+0 .. base+5
+func changeRelation(c *gin.Context, base int, msg string, change func(userID, targetID uint) error) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return
+	}
+	userID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return
+	}
+
+	targetID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || targetID64 == 0 {
+		Fail(c, base+2, "invalid target id")
+		return
+	}
+	targetID := uint(targetID64)
+
+	if err := change(userID, targetID); err != nil {
+		if errors.Is(err, service.ErrBlockSelf) || errors.Is(err, service.ErrMuteSelf) {
+			Fail(c, base+3, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			Fail(c, base+4, "user not found")
+			return
+		}
+		Fail(c, base+5, "db error")
+		return
+	}
+
+	OK(c, gin.H{
+		"msg":       msg,
+		"user_id":   userID,
+		"target_id": targetID,
+	})
+}
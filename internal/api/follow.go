@@ -3,9 +3,11 @@ package api
 import (
 	"errors"
 	"minifeed/internal/middleware"
+	"minifeed/internal/model"
 	"minifeed/internal/service"
 
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -35,19 +37,39 @@ func FollowRoutes(r *gin.Engine, followSvc *service.FollowService) {
 		}
 		targetID := uint(targetID64)
 
-		if err := followSvc.Follow(userID, targetID); err != nil {
+		requested, err := followSvc.Follow(userID, targetID)
+		if err != nil {
 			if errors.Is(err, service.ErrFollowSelf) {
 				Fail(c, 3004, "cannot follow yourself")
 				return
 			}
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 3006, "user not found")
+				return
+			}
+			if errors.Is(err, service.ErrBlocked) {
+				Fail(c, 3007, "blocked")
+				return
+			}
 			Fail(c, 3005, "db error")
 			return
 		}
 
+		if requested {
+			OK(c, gin.H{
+				"msg":       "follow requested",
+				"user_id":   userID,
+				"follow_id": targetID,
+				"requested": true,
+			})
+			return
+		}
+
 		OK(c, gin.H{
 			"msg":       "follow succeeded",
 			"user_id":   userID,
 			"follow_id": targetID,
+			"requested": false,
 		})
 
 	})
@@ -99,14 +121,28 @@ func FollowRoutes(r *gin.Engine, followSvc *service.FollowService) {
 			return
 		}
 
-		users, err := followSvc.ListFollowing(userID)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		users, nextCursor, err := followSvc.ListFollowing(userID, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				Fail(c, 3024, "invalid cursor")
+				return
+			}
+			Fail(c, 3023, "db error")
+			return
+		}
+
+		following, followers, err := followSvc.FollowCounts(userID)
 		if err != nil {
 			Fail(c, 3023, "db error")
 			return
 		}
 
 		OK(c, gin.H{
-			"list": users,
+			"list":            users,
+			"next_cursor":     nextCursor,
+			"following_count": following,
+			"follower_count":  followers,
 		})
 
 	})
@@ -124,14 +160,215 @@ func FollowRoutes(r *gin.Engine, followSvc *service.FollowService) {
 			return
 		}
 
-		users, err := followSvc.ListFollowers(userID)
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		users, nextCursor, err := followSvc.ListFollowers(userID, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				Fail(c, 3034, "invalid cursor")
+				return
+			}
+			Fail(c, 3033, "db error")
+			return
+		}
+
+		following, followers, err := followSvc.FollowCounts(userID)
 		if err != nil {
 			Fail(c, 3033, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":            users,
+			"next_cursor":     nextCursor,
+			"following_count": following,
+			"follower_count":  followers,
+		})
+
+	})
+
+	//===================== relationship status =======================
+	authGroup.GET("/relationships", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 3151, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 3152, "invalid user id")
+			return
+		}
+
+		var targetIDs []uint
+		for _, part := range strings.Split(c.Query("ids"), ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id64, err := strconv.ParseUint(part, 10, 64)
+			if err != nil || id64 == 0 {
+				Fail(c, 3153, "invalid ids")
+				return
+			}
+			targetIDs = append(targetIDs, uint(id64))
+		}
+
+		rels, err := followSvc.Relationships(userID, targetIDs)
+		if err != nil {
+			if errors.Is(err, service.ErrTooManyIDs) {
+				Fail(c, 3154, "too many ids")
+				return
+			}
+			Fail(c, 3155, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list": rels,
+		})
+	})
+
+	//===================== who to follow ===========================
+	authGroup.GET("/suggestions", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 3161, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 3162, "invalid user id")
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+		list, err := followSvc.Suggestions(userID, limit)
+		if err != nil {
+			Fail(c, 3163, "db error")
+			return
 		}
 
 		OK(c, gin.H{
-			"list": users,
+			"list": list,
 		})
+	})
+
+	//===================== follow requests ===========================
+	authGroup.GET("/follow-requests/incoming", func(c *gin.Context) {
+		listUserPage(c, 3101, followSvc.ListIncomingRequests)
+	})
+
+	authGroup.GET("/follow-requests/outgoing", func(c *gin.Context) {
+		listUserPage(c, 3111, followSvc.ListOutgoingRequests)
+	})
+
+	authGroup.POST("/follow-requests/:id/approve", func(c *gin.Context) {
+		answerFollowRequest(c, 3121, "approved", followSvc.ApproveRequest)
+	})
+
+	authGroup.POST("/follow-requests/:id/reject", func(c *gin.Context) {
+		answerFollowRequest(c, 3131, "rejected", followSvc.RejectRequest)
+	})
+
+	//===================== account privacy ===========================
+	authGroup.PUT("/me/privacy", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 3141, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 3142, "invalid user id")
+			return
+		}
+
+		var req struct {
+			IsPrivate *bool `json:"is_private"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.IsPrivate == nil {
+			Fail(c, 3143, "invalid request")
+			return
+		}
+
+		if err := followSvc.SetPrivate(userID, *req.IsPrivate); err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 3144, "user not found")
+				return
+			}
+			Fail(c, 3145, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"user_id":    userID,
+			"is_private": *req.IsPrivate,
+		})
+	})
+}
+
+// one page of a user list (pending requests, blocks, mutes); codes are base+0 .. base+3
+func listUserPage(c *gin.Context, base int, list func(userID uint, limit int, cursor string) ([]model.User, string, error)) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return
+	}
+	userID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	users, nextCursor, err := list(userID, limit, c.Query("cursor"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			Fail(c, base+2, "invalid cursor")
+			return
+		}
+		Fail(c, base+3, "db error")
+		return
+	}
+
+	OK(c, gin.H{
+		"list":        users,
+		"next_cursor": nextCursor,
+	})
+}
+
+// approves or rejects the request the user in :id sent me; codes are base+0 .. base+4
+func answerFollowRequest(c *gin.Context, base int, msg string, answer func(targetID, requesterID uint) error) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return
+	}
+	userID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return
+	}
+
+	requesterID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || requesterID64 == 0 {
+		Fail(c, base+2, "invalid requester id")
+		return
+	}
+	requesterID := uint(requesterID64)
+
+	if err := answer(userID, requesterID); err != nil {
+		if errors.Is(err, service.ErrFollowRequestNotFound) {
+			Fail(c, base+3, "follow request not found")
+			return
+		}
+		Fail(c, base+4, "db error")
+		return
+	}
 
+	OK(c, gin.H{
+		"msg":     "follow request " + msg,
+		"user_id": requesterID,
 	})
 }
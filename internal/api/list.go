@@ -0,0 +1,297 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"minifeed/internal/middleware"
+	"minifeed/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListRoutes(r *gin.Engine, listSvc *service.ListService) {
+	authGroup := r.Group("/api", middleware.Auth())
+
+	//=================== create a list ===================
+	authGroup.POST("/lists", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 8001, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 8002, "invalid user id")
+			return
+		}
+
+		var req struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			IsPrivate   bool   `json:"is_private"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Fail(c, 8003, "invalid request!")
+			return
+		}
+
+		l, err := listSvc.CreateList(userID, req.Name, req.Description, req.IsPrivate)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidList) {
+				Fail(c, 8004, err.Error())
+				return
+			}
+			if errors.Is(err, service.ErrTooManyLists) {
+				Fail(c, 8005, "too many lists")
+				return
+			}
+			Fail(c, 8006, "db error")
+			return
+		}
+
+		OK(c, l)
+	})
+
+	//=================== lists of a user (default: mine) ===================
+	authGroup.GET("/lists", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 8011, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 8012, "invalid user id")
+			return
+		}
+
+		ownerID := userID
+		if ownerStr := c.Query("user_id"); ownerStr != "" {
+			owner64, err := strconv.ParseUint(ownerStr, 10, 64)
+			if err != nil || owner64 == 0 {
+				Fail(c, 8013, "invalid owner id")
+				return
+			}
+			ownerID = uint(owner64)
+		}
+
+		lists, err := listSvc.ListLists(userID, ownerID)
+		if err != nil {
+			Fail(c, 8014, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list": lists,
+		})
+	})
+
+	//=================== one list ===================
+	authGroup.GET("/lists/:id", func(c *gin.Context) {
+		userID, listID, ok := listRequest(c, 8021)
+		if !ok {
+			return
+		}
+
+		l, err := listSvc.GetList(userID, listID)
+		if err != nil {
+			if errors.Is(err, service.ErrListNotFound) {
+				Fail(c, 8024, "list not found")
+				return
+			}
+			Fail(c, 8025, "db error")
+			return
+		}
+
+		OK(c, l)
+	})
+
+	authGroup.PATCH("/lists/:id", func(c *gin.Context) {
+		userID, listID, ok := listRequest(c, 8031)
+		if !ok {
+			return
+		}
+
+		var req service.ListUpdate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Fail(c, 8034, "invalid request!")
+			return
+		}
+
+		l, err := listSvc.UpdateList(userID, listID, req)
+		if err != nil {
+			if errors.Is(err, service.ErrListNotFound) {
+				Fail(c, 8035, "list not found")
+				return
+			}
+			if errors.Is(err, service.ErrNotListOwner) {
+				Fail(c, 8036, "not the owner of the list")
+				return
+			}
+			if errors.Is(err, service.ErrInvalidList) {
+				Fail(c, 8037, err.Error())
+				return
+			}
+			Fail(c, 8038, "db error")
+			return
+		}
+
+		OK(c, l)
+	})
+
+	authGroup.DELETE("/lists/:id", func(c *gin.Context) {
+		userID, listID, ok := listRequest(c, 8041)
+		if !ok {
+			return
+		}
+
+		if err := listSvc.DeleteList(userID, listID); err != nil {
+			if errors.Is(err, service.ErrListNotFound) {
+				Fail(c, 8044, "list not found")
+				return
+			}
+			if errors.Is(err, service.ErrNotListOwner) {
+				Fail(c, 8045, "not the owner of the list")
+				return
+			}
+			Fail(c, 8046, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"msg":     "list deleted",
+			"list_id": listID,
+		})
+	})
+
+	//=================== members ===================
+	authGroup.GET("/lists/:id/members", func(c *gin.Context) {
+		userID, listID, ok := listRequest(c, 8051)
+		if !ok {
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		users, nextCursor, err := listSvc.ListMembers(userID, listID, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, service.ErrListNotFound) {
+				Fail(c, 8054, "list not found")
+				return
+			}
+			if errors.Is(err, service.ErrInvalidCursor) {
+				Fail(c, 8055, "invalid cursor")
+				return
+			}
+			Fail(c, 8056, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        users,
+			"next_cursor": nextCursor,
+		})
+	})
+
+	authGroup.POST("/lists/:id/members/:user_id", func(c *gin.Context) {
+		changeListMember(c, 8061, "member added", listSvc.AddMember)
+	})
+
+	authGroup.DELETE("/lists/:id/members/:user_id", func(c *gin.Context) {
+		changeListMember(c, 8071, "member removed", listSvc.RemoveMember)
+	})
+
+	//=================== posts of the list's members ===================
+	authGroup.GET("/lists/:id/feed", func(c *gin.Context) {
+		userID, listID, ok := listRequest(c, 8081)
+		if !ok {
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		//cursor: next_cursor of the previous page
+		var cursor uint64
+		if cursorStr := c.Query("cursor"); cursorStr != "" {
+			if cVal, err := strconv.ParseUint(cursorStr, 10, 64); err == nil && cVal > 0 {
+				cursor = cVal
+			}
+		}
+
+		posts, nextCursor, err := listSvc.ListFeed(userID, listID, limit, cursor)
+		if err != nil {
+			if errors.Is(err, service.ErrListNotFound) {
+				Fail(c, 8084, "list not found")
+				return
+			}
+			Fail(c, 8085, "db or cache error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        posts,
+			"next_cursor": nextCursor,
+		})
+	})
+}
+
+// the current user and the list in :id; codes are base+0 .. base+2
+func listRequest(c *gin.Context, base int) (uint, uint, bool) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return 0, 0, false
+	}
+	userID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return 0, 0, false
+	}
+
+	listID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || listID64 == 0 {
+		Fail(c, base+2, "invalid list id")
+		return 0, 0, false
+	}
+	return userID, uint(listID64), true
+}
+
+// adds or removes the user in :user_id on my list in :id; codes are base+0 .. base+8
+func changeListMember(c *gin.Context, base int, msg string, change func(ownerID, listID, userID uint) error) {
+	userID, listID, ok := listRequest(c, base)
+	if !ok {
+		return
+	}
+
+	memberID64, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil || memberID64 == 0 {
+		Fail(c, base+3, "invalid target id")
+		return
+	}
+	memberID := uint(memberID64)
+
+	if err := change(userID, listID, memberID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrListNotFound):
+			Fail(c, base+4, "list not found")
+		case errors.Is(err, service.ErrNotListOwner):
+			Fail(c, base+5, "not the owner of the list")
+		case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrNotListMember):
+			Fail(c, base+6, err.Error())
+		case errors.Is(err, service.ErrBlocked), errors.Is(err, service.ErrListFull):
+			Fail(c, base+7, err.Error())
+		default:
+			Fail(c, base+8, "db error")
+		}
+		return
+	}
+
+	OK(c, gin.H{
+		"msg":     msg,
+		"list_id": listID,
+		"user_id": memberID,
+	})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"minifeed/internal/middleware"
+	"minifeed/internal/model"
+	"minifeed/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func ModerationRoutes(r *gin.Engine, modSvc *service.ModerationService) {
+	authGroup := r.Group("/api", middleware.Auth())
+
+	//=================== report a post or a user ===================
+	authGroup.POST("/reports", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 9001, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 9002, "invalid user id")
+			return
+		}
+
+		var req struct {
+			TargetType string `json:"target_type"`
+			TargetID   uint   `json:"target_id"`
+			Reason     string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.TargetID == 0 {
+			Fail(c, 9003, "invalid request!")
+			return
+		}
+
+		mc, err := modSvc.Report(userID, req.TargetType, req.TargetID, req.Reason)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidReport) || errors.Is(err, service.ErrReportSelf) {
+				Fail(c, 9004, err.Error())
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 9005, "target not found")
+				return
+			}
+			if errors.Is(err, service.ErrAlreadyReported) {
+				Fail(c, 9006, "already reported")
+				return
+			}
+			Fail(c, 9007, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"msg":     "report received",
+			"case_id": mc.ID,
+		})
+	})
+
+	//=================== warnings I received ===================
+	authGroup.GET("/me/warnings", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 9011, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 9012, "invalid user id")
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+
+		warnings, nextCursor, err := modSvc.ListWarnings(userID, limit, cursor)
+		if err != nil {
+			Fail(c, 9013, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        warnings,
+			"next_cursor": nextCursor,
+		})
+	})
+
+	//=================== review queue (moderators and admins) ===================
+	modGroup := r.Group("/api/moderation", middleware.Auth(), middleware.RequireRole(model.RoleModerator, model.RoleAdmin))
+
+	modGroup.GET("/cases", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+
+		items, nextCursor, err := modSvc.ListCases(c.DefaultQuery("status", "pending"), limit, cursor)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCaseStatus) {
+				Fail(c, 9021, "invalid status")
+				return
+			}
+			Fail(c, 9022, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        items,
+			"next_cursor": nextCursor,
+		})
+	})
+
+	modGroup.POST("/cases/:id", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 9031, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 9032, "invalid user id")
+			return
+		}
+
+		caseID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || caseID64 == 0 {
+			Fail(c, 9033, "invalid case id")
+			return
+		}
+
+		var req struct {
+			Action string `json:"action"`
+			Note   string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Fail(c, 9034, "invalid request!")
+			return
+		}
+
+		mc, err := modSvc.Resolve(userID, uint(caseID64), req.Action, req.Note)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAction) {
+				Fail(c, 9035, err.Error())
+				return
+			}
+			if errors.Is(err, service.ErrCaseNotFound) {
+				Fail(c, 9036, "case not found")
+				return
+			}
+			if errors.Is(err, service.ErrCaseResolved) {
+				Fail(c, 9037, "case already resolved")
+				return
+			}
+			Fail(c, 9038, "db error")
+			return
+		}
+
+		OK(c, mc)
+	})
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"errors"
 	"strconv"
+	"time"
 
+	"minifeed/internal/dao"
 	"minifeed/internal/middleware"
 	"minifeed/internal/service"
 
@@ -40,6 +42,10 @@ func PostRoutes(r *gin.Engine, svc *service.PostService) {
 
 			post, err := svc.CreatePost(userID, req.Content, req.ImageURL)
 			if err != nil {
+				if errors.Is(err, service.ErrBannedContent) {
+					Fail(c, 4005, "content contains banned terms")
+					return
+				}
 				Fail(c, 5001, "db error")
 				return
 			}
@@ -97,8 +103,165 @@ func PostRoutes(r *gin.Engine, svc *service.PostService) {
 
 	}
 
+	//========================= a user's profile timeline ==============================
+	authGroup.GET("/users/:id/posts", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 5021, "no user in context")
+			return
+		}
+		viewerID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 5022, "invalid user id")
+			return
+		}
+
+		authorID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || authorID64 == 0 {
+			Fail(c, 5023, "invalid target id")
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 10
+		}
+
+		//cursor: next_cursor of the previous page
+		var cursor uint64
+		if cursorStr := c.Query("cursor"); cursorStr != "" {
+			if cVal, err := strconv.ParseUint(cursorStr, 10, 64); err == nil && cVal > 0 {
+				cursor = cVal
+			}
+		}
+
+		pinned, posts, nextCursor, err := svc.ListUserPosts(viewerID, uint(authorID64), limit, cursor)
+		if err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 5024, "user not found")
+				return
+			}
+			if errors.Is(err, service.ErrPrivateAccount) {
+				Fail(c, 5025, "account is private")
+				return
+			}
+			Fail(c, 5026, "db or cache error")
+			return
+		}
+
+		OK(c, gin.H{
+			"pinned":      pinned,
+			"list":        posts,
+			"next_cursor": nextCursor,
+		})
+	})
+
+	//============================ pin and unpin ===================================
+	authGroup.POST("/post/:id/pin", func(c *gin.Context) {
+		changePost(c, 5031, "pin succeeded", svc.PinPost)
+	})
+	authGroup.DELETE("/post/:id/pin", func(c *gin.Context) {
+		changePost(c, 5041, "unpin succeeded", svc.UnpinPost)
+	})
+
+	//============================ bookmarks ===================================
+	authGroup.POST("/post/:id/bookmark", func(c *gin.Context) {
+		changePost(c, 5051, "bookmark succeeded", svc.Bookmark)
+	})
+	authGroup.DELETE("/post/:id/bookmark", func(c *gin.Context) {
+		changePost(c, 5061, "unbookmark succeeded", svc.Unbookmark)
+	})
+
+	authGroup.GET("/bookmarks", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 5071, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 5072, "invalid user id")
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		posts, nextCursor, err := svc.ListBookmarks(userID, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				Fail(c, 5073, "invalid cursor")
+				return
+			}
+			Fail(c, 5074, "db or cache error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        posts,
+			"next_cursor": nextCursor,
+		})
+	})
+
+	//============================ post search ===================================
+	authGroup.GET("/search/posts", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 4011, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 4012, "invalid user id")
+			return
+		}
+
+		req := service.PostSearch{
+			Q:       c.Query("q"),
+			Hashtag: c.Query("hashtag"),
+			Sort:    c.DefaultQuery("sort", dao.SearchSortRelevance),
+		}
+		if req.Sort != dao.SearchSortRelevance && req.Sort != dao.SearchSortRecent {
+			Fail(c, 4013, "invalid search filter")
+			return
+		}
+		if authorStr := c.Query("author_id"); authorStr != "" {
+			author64, err := strconv.ParseUint(authorStr, 10, 64)
+			if err != nil || author64 == 0 {
+				Fail(c, 4013, "invalid search filter")
+				return
+			}
+			req.AuthorID = uint(author64)
+		}
+		var okSince, okUntil bool
+		req.Since, okSince = parseSearchTime(c.Query("since"), false)
+		req.Until, okUntil = parseSearchTime(c.Query("until"), true)
+		if !okSince || !okUntil {
+			Fail(c, 4013, "invalid search filter")
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		posts, nextCursor, err := svc.SearchPosts(userID, req, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, dao.ErrEmptySearch) {
+				Fail(c, 4014, "empty search")
+				return
+			}
+			if errors.Is(err, dao.ErrInvalidSearchCursor) {
+				Fail(c, 4015, "invalid cursor")
+				return
+			}
+			Fail(c, 4016, "search error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        posts,
+			"next_cursor": nextCursor,
+		})
+	})
+
 	//=============== public: newest first + cursor-based pagination =======================
-	r.GET("/posts", func(c *gin.Context) {
+	r.GET("/posts", middleware.OptionalAuth(), func(c *gin.Context) {
 		//limit: number per page
 		limitStr := c.DefaultQuery("limit", "10")
 		limit, err := strconv.Atoi(limitStr)
@@ -115,7 +278,10 @@ func PostRoutes(r *gin.Engine, svc *service.PostService) {
 			}
 		}
 
-		posts, nextCursor, err := svc.ListPublicPosts(limit, cursor)
+		// signed-in readers also get block/mute filtering; 0 = anonymous
+		viewerID := c.GetUint("user_id")
+
+		posts, nextCursor, err := svc.ListPublicPosts(viewerID, limit, cursor)
 		if err != nil {
 			Fail(c, 5002, "db error")
 			return
@@ -207,16 +373,33 @@ func PostRoutes(r *gin.Engine, svc *service.PostService) {
 
 	})
 
-	//=================================== hot posts feed (by like_count, cached in Redis) ================================
+	//=================================== hot posts feed (time-decayed score per window, cached in Redis) ================================
 	authGroup.GET("/feed/hot", func(c *gin.Context) {
 
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 5005, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 5006, "invalid user id")
+			return
+		}
+
+		window := c.DefaultQuery("window", dao.DefaultHotWindow)
+		if _, ok := dao.HotWindows[window]; !ok {
+			Fail(c, 5004, "invalid window, use 1h, 24h or 7d")
+			return
+		}
+
 		limitStr := c.DefaultQuery("limit", "10")
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil || limit <= 0 || limit > 50 {
 			limit = 10
 		}
 
-		posts, err := svc.ListHotPosts(limit)
+		posts, err := svc.ListHotPosts(userID, window, limit)
 		if err != nil {
 			Fail(c, 5003, "db or cache error")
 			return
@@ -228,4 +411,103 @@ func PostRoutes(r *gin.Engine, svc *service.PostService) {
 
 	})
 
+	//=================================== personalized ranked home feed ================================
+	authGroup.GET("/feed/ranked", func(c *gin.Context) {
+
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 5011, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 5012, "invalid user id")
+			return
+		}
+
+		limitStr := c.DefaultQuery("limit", "10")
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 50 {
+			limit = 10
+		}
+
+		//cursor: next_cursor of the previous page, empty to rank afresh
+		posts, nextCursor, err := svc.ListRankedFeed(userID, limit, c.Query("cursor"))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCursor) {
+				Fail(c, 5013, "invalid cursor")
+				return
+			}
+			if errors.Is(err, dao.ErrSnapshotExpired) {
+				Fail(c, 5014, "ranking expired, reload without cursor")
+				return
+			}
+			Fail(c, 5015, "db or cache error")
+			return
+		}
+
+		OK(c, gin.H{
+			"list":        posts,
+			"next_cursor": nextCursor,
+		})
+
+	})
+
+}
+
+// applies a change of mine to the post in :id (pin, bookmark, ...); codes are base+0 .. base+5
+func changePost(c *gin.Context, base int, msg string, change func(userID, postID uint) error) {
+	uidVal, ok := c.Get("user_id")
+	if !ok {
+		Fail(c, base, "no user in context")
+		return
+	}
+	userID, ok := uidVal.(uint)
+	if !ok {
+		Fail(c, base+1, "invalid user id")
+		return
+	}
+
+	postID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || postID64 == 0 {
+		Fail(c, base+2, "invalid post id")
+		return
+	}
+	postID := uint(postID64)
+
+	if err := change(userID, postID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, service.ErrNotPostOwner) {
+			Fail(c, base+3, "post not found")
+			return
+		}
+		if errors.Is(err, service.ErrTooManyPinned) {
+			Fail(c, base+4, "too many pinned posts")
+			return
+		}
+		Fail(c, base+5, "db error")
+		return
+	}
+
+	OK(c, gin.H{
+		"msg":     msg,
+		"post_id": postID,
+	})
+}
+
+// "2006-01-02" or RFC 3339; a bare date used as an upper bound covers the whole day
+func parseSearchTime(v string, upper bool) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -90,6 +91,10 @@ func UserRoutes(r *gin.Engine, userSvc *service.UserService) {
 				Fail(c, 2005, "wrong password")
 				return
 			}
+			if errors.Is(err, service.ErrUserSuspended) {
+				Fail(c, 2006, "account suspended")
+				return
+			}
 			Fail(c, 2004, "db error")
 			return
 		}
@@ -97,22 +102,54 @@ func UserRoutes(r *gin.Engine, userSvc *service.UserService) {
 		OK(c, gin.H{
 			"user_id":  u.ID,
 			"username": u.Username,
+			"role":     u.Role,
 			"msg":      "login succeeded",
 			"token":    token,
 		})
 	})
 
+	//==================== username availability =======================
+	r.GET("/user/available", func(c *gin.Context) {
+		username := c.Query("username")
+		if username == "" {
+			Fail(c, 1011, "username is empty")
+			return
+		}
+
+		available, err := userSvc.UsernameAvailable(username)
+		if err != nil {
+			Fail(c, 1012, "db error")
+			return
+		}
+
+		OK(c, gin.H{
+			"username":  username,
+			"available": available,
+		})
+	})
+
 	//================================== User APIs (Require Authentication) =============================
 	authGroup := r.Group("/api", middleware.Auth())
 
 	authGroup.GET("/users/search", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 4003, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 4004, "invalid user id")
+			return
+		}
+
 		keyword := c.Query("keyword")
 		if keyword == "" {
 			Fail(c, 4001, "keyword is empty")
 			return
 		}
 
-		users, err := userSvc.SearchByUsername(keyword, 20)
+		users, err := userSvc.SearchByUsername(userID, keyword, 20)
 		if err != nil {
 			Fail(c, 4002, "db error")
 			return
@@ -123,13 +160,96 @@ func UserRoutes(r *gin.Engine, userSvc *service.UserService) {
 		})
 	})
 
+	//==================== profiles =======================
+	authGroup.GET("/users/:id", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 1021, "no user in context")
+			return
+		}
+		viewerID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 1022, "invalid user id")
+			return
+		}
+
+		targetID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || targetID64 == 0 {
+			Fail(c, 1023, "invalid target id")
+			return
+		}
+
+		profile, err := userSvc.GetProfile(viewerID, uint(targetID64))
+		if err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 1024, "user not found")
+				return
+			}
+			Fail(c, 1025, "db error")
+			return
+		}
+
+		OK(c, profile)
+	})
+
 	authGroup.GET("/me", func(c *gin.Context) {
-		userID, _ := c.Get("user_id")
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 1031, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 1032, "invalid user id")
+			return
+		}
 
-		c.JSON(200, gin.H{
-			"msg":     "Hello user",
-			"user_id": userID,
-		})
+		profile, err := userSvc.GetProfile(userID, userID)
+		if err != nil {
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 1033, "user not found")
+				return
+			}
+			Fail(c, 1034, "db error")
+			return
+		}
+
+		OK(c, profile)
+	})
+
+	authGroup.PATCH("/me", func(c *gin.Context) {
+		uidVal, ok := c.Get("user_id")
+		if !ok {
+			Fail(c, 1041, "no user in context")
+			return
+		}
+		userID, ok := uidVal.(uint)
+		if !ok {
+			Fail(c, 1042, "invalid user id")
+			return
+		}
+
+		var req service.ProfileUpdate
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Fail(c, 1043, "invalid request!")
+			return
+		}
+
+		profile, err := userSvc.UpdateProfile(userID, req)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidProfile) {
+				Fail(c, 1044, err.Error())
+				return
+			}
+			if errors.Is(err, service.ErrUserNotFound) {
+				Fail(c, 1045, "user not found")
+				return
+			}
+			Fail(c, 1046, "db error")
+			return
+		}
+
+		OK(c, profile)
 	})
 
 }
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"minifeed/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// returned by Get for keys whose loader reported ErrNotFound (negative caching)
+var ErrNotFound = errors.New("cache: not found")
+
+// loads the value of a key from the source of truth
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// distributed lock used to let a single replica rebuild a key
+type Locker interface {
+	TryLock(key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+type Options struct {
+	// base TTL of a loaded value, plus a random [0, Jitter) so keys don't expire together
+	TTL    time.Duration
+	Jitter time.Duration
+
+	// TTL of the marker stored when the loader returns ErrNotFound; 0 disables negative caching
+	NegativeTTL time.Duration
+
+	// XFetch beta: > 0 refreshes a value in the background before it expires,
+	// earlier for values that were slow to load; 0 disables early refresh
+	Beta float64
+
+	// delay of the second delete in Invalidate; defaults to 100ms
+	DoubleDeleteDelay time.Duration
+
+	// rebuilds take Locker first; callers that lose the race serve the stale
+	// copy (kept for StaleTTL, 0 disables it) or poll for the rebuilt value
+	Locker       Locker
+	StaleTTL     time.Duration
+	LockTTL      time.Duration
+	PollInterval time.Duration
+	PollTimes    int
+
+	// called with the Redis keys after every delete, e.g. to evict L1 copies
+	OnDelete func(keys ...string)
+}
+
+// what is stored in Redis for each key
+type entry[V any] struct {
+	Value    V     `json:"v"`
+	Negative bool  `json:"n,omitempty"`
+	Delta    int64 `json:"d"` // load time in ms
+	Expiry   int64 `json:"e"` // unix ms
+}
+
+// typed cache-aside helper over Redis
+type Cache[K comparable, V any] struct {
+	name  string
+	rdb   redis.Cmdable
+	key   func(K) string
+	opts  Options
+	group singleflight.Group
+}
+
+func New[K comparable, V any](name string, rdb redis.Cmdable, key func(K) string, opts Options) *Cache[K, V] {
+	if opts.DoubleDeleteDelay <= 0 {
+		opts.DoubleDeleteDelay = 100 * time.Millisecond
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = 5 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 50 * time.Millisecond
+	}
+	if opts.PollTimes <= 0 {
+		opts.PollTimes = 20
+	}
+
+	return &Cache[K, V]{
+		name: name,
+		rdb:  rdb,
+		key:  key,
+		opts: opts,
+	}
+}
+
+func (c *Cache[K, V]) staleKey(k string) string {
+	return k + ":stale"
+}
+
+func (c *Cache[K, V]) lockKey(k string) string {
+	return "lock:" + k
+}
+
+// read-through: returns the cached value or loads it, coalescing concurrent rebuilds
+func (c *Cache[K, V]) Get(ctx context.Context, key K, load Loader[K, V]) (V, error) {
+	k := c.key(key)
+
+	e, err := c.read(ctx, k)
+	if err == nil {
+		metrics.CacheRequestsTotal.WithLabelValues(c.name, "redis", "hit").Inc()
+		if c.shouldRefreshEarly(e) {
+			go c.group.Do(k, func() (interface{}, error) {
+				return c.loadAndStore(context.Background(), key, k, load)
+			})
+		}
+		return c.unwrap(e)
+	}
+	metrics.CacheRequestsTotal.WithLabelValues(c.name, "redis", "miss").Inc()
+
+	v, err, _ := c.group.Do(k, func() (interface{}, error) {
+		return c.rebuild(ctx, key, k, load)
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return c.unwrap(v.(*entry[V]))
+}
+
+// loads and stores the value regardless of what is cached
+func (c *Cache[K, V]) Refresh(ctx context.Context, key K, load Loader[K, V]) (V, error) {
+	k := c.key(key)
+	v, err, _ := c.group.Do(k, func() (interface{}, error) {
+		return c.loadAndStore(ctx, key, k, load)
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return c.unwrap(v.(*entry[V]))
+}
+
+// writes a value directly
+func (c *Cache[K, V]) Set(ctx context.Context, key K, v V) error {
+	return c.store(ctx, c.key(key), &entry[V]{Value: v})
+}
+
+// delete before write
+func (c *Cache[K, V]) Del(ctx context.Context, keys ...K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ks := make([]string, len(keys))
+	for i, key := range keys {
+		ks[i] = c.key(key)
+	}
+
+	err := c.rdb.Del(ctx, ks...).Err()
+	if c.opts.OnDelete != nil {
+		c.opts.OnDelete(ks...)
+	}
+	return err
+}
+
+// delete after write
+func (c *Cache[K, V]) DelAsync(keys ...K) {
+	go func() {
+		time.Sleep(c.opts.DoubleDeleteDelay)
+		_ = c.Del(context.Background(), keys...)
+	}()
+}
+
+// delayed double delete around a write that has already happened
+func (c *Cache[K, V]) Invalidate(ctx context.Context, keys ...K) error {
+	err := c.Del(ctx, keys...)
+	c.DelAsync(keys...)
+	return err
+}
+
+func (c *Cache[K, V]) read(ctx context.Context, k string) (*entry[V], error) {
+	data, err := c.rdb.Get(ctx, k).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var e entry[V]
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Cache[K, V]) unwrap(e *entry[V]) (V, error) {
+	if e.Negative {
+		var zero V
+		return zero, ErrNotFound
+	}
+	return e.Value, nil
+}
+
+// XFetch: refresh with a probability that grows as expiry approaches
+func (c *Cache[K, V]) shouldRefreshEarly(e *entry[V]) bool {
+	if c.opts.Beta <= 0 || e.Negative || e.Expiry == 0 {
+		return false
+	}
+	now := time.Now().UnixMilli()
+	gap := -float64(e.Delta) * c.opts.Beta * math.Log(rand.Float64())
+	return float64(now)+gap >= float64(e.Expiry)
+}
+
+// rebuilds under the lock, or serves stale / polls while another replica rebuilds
+func (c *Cache[K, V]) rebuild(ctx context.Context, key K, k string, load Loader[K, V]) (*entry[V], error) {
+	if c.opts.Locker == nil {
+		return c.loadAndStore(ctx, key, k, load)
+	}
+
+	unlock, ok, err := c.opts.Locker.TryLock(c.lockKey(k), c.opts.LockTTL)
+	if err == nil && ok {
+		defer unlock()
+
+		if e, err := c.read(ctx, k); err == nil {
+			return e, nil
+		}
+		return c.loadAndStore(ctx, key, k, load)
+	}
+
+	if c.opts.StaleTTL > 0 {
+		if e, err := c.read(ctx, c.staleKey(k)); err == nil {
+			metrics.CacheRequestsTotal.WithLabelValues(c.name, "stale", "hit").Inc()
+			return e, nil
+		}
+	}
+
+	for i := 0; i < c.opts.PollTimes; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.opts.PollInterval):
+		}
+		if e, err := c.read(ctx, k); err == nil {
+			return e, nil
+		}
+	}
+
+	// the holder is taking too long; load without writing so we don't race it
+	return c.load(ctx, key, load)
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, key K, load Loader[K, V]) (*entry[V], error) {
+	start := time.Now()
+	v, err := load(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &entry[V]{Negative: true}, nil
+		}
+		return nil, err
+	}
+	return &entry[V]{Value: v, Delta: max(time.Since(start).Milliseconds(), 1)}, nil
+}
+
+func (c *Cache[K, V]) loadAndStore(ctx context.Context, key K, k string, load Loader[K, V]) (*entry[V], error) {
+	e, err := c.load(ctx, key, load)
+	if err != nil {
+		return nil, err
+	}
+	if e.Negative && c.opts.NegativeTTL <= 0 {
+		return e, nil
+	}
+
+	// a failed write only costs a future miss, the caller still gets the value
+	_ = c.store(ctx, k, e)
+	return e, nil
+}
+
+func (c *Cache[K, V]) store(ctx context.Context, k string, e *entry[V]) error {
+	ttl := c.opts.TTL
+	if e.Negative {
+		ttl = c.opts.NegativeTTL
+	} else if c.opts.Jitter > 0 {
+		ttl += time.Duration(rand.Int63n(int64(c.opts.Jitter)))
+	}
+	if ttl > 0 {
+		e.Expiry = time.Now().Add(ttl).UnixMilli()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	pipe := c.rdb.TxPipeline()
+	pipe.Set(ctx, k, data, ttl)
+	if c.opts.StaleTTL > 0 {
+		if e.Negative {
+			pipe.Del(ctx, c.staleKey(k))
+		} else {
+			pipe.Set(ctx, c.staleKey(k), data, c.opts.StaleTTL)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
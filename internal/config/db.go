@@ -5,6 +5,7 @@ import (
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
 	"minifeed/internal/model"
@@ -18,10 +19,50 @@ func InitDB(dsn string) *gorm.DB {
 		log.Fatalf("connect mysql err: %v", err)
 	}
 
-	if err := db.AutoMigrate(&model.User{}, &model.Post{}, &model.Follow{}); err != nil {
+	hadFollowCounts := db.Migrator().HasColumn(&model.User{}, "follower_count")
+	hadHashtags := db.Migrator().HasTable(&model.PostHashtag{})
+
+	if err := db.AutoMigrate(&model.User{}, &model.Post{}, &model.Follow{}, &model.FollowRequest{}, &model.Block{}, &model.Mute{}, &model.Interaction{}, &model.FeedImpression{}, &model.List{}, &model.ListMember{}, &model.Bookmark{}, &model.PostHashtag{}, &model.ModerationCase{}, &model.Report{}, &model.Warning{}, &model.AuditLog{}); err != nil {
 		log.Fatalf("auto migrate err: %v", err)
 	}
 
+	if !hadFollowCounts {
+		if err := backfillFollowCounts(db); err != nil {
+			log.Fatalf("backfill follow counts err: %v", err)
+		}
+	}
+
+	if !hadHashtags {
+		if err := backfillHashtags(db); err != nil {
+			log.Fatalf("backfill hashtags err: %v", err)
+		}
+	}
+
 	return db
 
 }
+
+// fills the denormalized follow counters the first time the columns appear
+func backfillFollowCounts(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET
+		follower_count = (SELECT COUNT(*) FROM follows WHERE follows.follow_id = users.id),
+		following_count = (SELECT COUNT(*) FROM follows WHERE follows.user_id = users.id)`).Error
+}
+
+// extracts the hashtags of posts written before the table existed
+func backfillHashtags(db *gorm.DB) error {
+	var posts []model.Post
+	return db.Select("id", "content").Where("content LIKE ?", "%#%").
+		FindInBatches(&posts, 1000, func(tx *gorm.DB, _ int) error {
+			var rows []model.PostHashtag
+			for _, p := range posts {
+				for _, tag := range model.ExtractHashtags(p.Content) {
+					rows = append(rows, model.PostHashtag{Tag: tag, PostID: p.ID})
+				}
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+		}).Error
+}
@@ -0,0 +1,53 @@
+package cron
+
+import (
+	"log"
+	"time"
+
+	"minifeed/internal/dao"
+
+	"gorm.io/gorm"
+)
+
+// held by the replica rebuilding a shared (Redis) filter
+const bloomRebuildLockKey = "lock:cron:bloom"
+
+// rebuild the Bloom filters periodically and publish their fill metrics;
+// a shared filter is rebuilt by one replica at a time
+func StartBloomRebuild(db *gorm.DB, shared bool) {
+	dao.UpdateBloomMetrics()
+
+	rebuildTicker := time.NewTicker(1 * time.Hour)
+	metricsTicker := time.NewTicker(30 * time.Second)
+
+	go func() {
+		for {
+			select {
+			case <-rebuildTicker.C:
+				rebuildBloom(db, shared)
+			case <-metricsTicker.C:
+				dao.UpdateBloomMetrics()
+			}
+		}
+	}()
+}
+
+func rebuildBloom(db *gorm.DB, shared bool) {
+	rebuild := func() error {
+		return dao.RebuildBlooms(db)
+	}
+
+	var err error
+	if shared {
+		_, err = dao.WithLock(bloomRebuildLockKey, 10*time.Minute, rebuild)
+	} else {
+		err = rebuild()
+	}
+	if err != nil {
+		log.Printf("[cron] rebuild bloom filters failed: %v\n", err)
+		return
+	}
+
+	dao.UpdateBloomMetrics()
+	log.Println("[cron] bloom filters rebuilt")
+}
@@ -8,24 +8,35 @@ import (
 	"gorm.io/gorm"
 )
 
-// refresh hot posts cache periodically
+// held by the replica currently refreshing, so rankings are decayed once per tick cluster-wide
+const hotRefreshLockKey = "lock:cron:hot"
+
+// decay the hot rankings and refresh hot posts cache periodically
 func StartHotPostsRefresh(db *gorm.DB) {
-	if err := dao.RefreshHotPostsCache(db); err != nil {
-		log.Printf("[cron] refresh hot posts cache failed: %v\n", err)
-	} else {
-		log.Println("[cron] hot posts cache refreshed")
-	}
+	refreshHotPosts(db)
 
 	ticker := time.NewTicker(1 * time.Minute)
 
 	go func() {
 		for range ticker.C {
-			if err := dao.RefreshHotPostsCache(db); err != nil {
-				log.Printf("[cron] refresh hot posts cache failed: %v\n", err)
-			} else {
-				log.Println("[cron] hot posts cache refreshed")
-			}
+			refreshHotPosts(db)
 		}
 	}()
 
 }
+
+func refreshHotPosts(db *gorm.DB) {
+	ran, err := dao.WithLock(hotRefreshLockKey, 50*time.Second, func() error {
+		if err := dao.DecayHotRanks(db); err != nil {
+			log.Printf("[cron] decay hot rankings failed: %v\n", err)
+		}
+		return dao.RefreshHotPostsCache(db)
+	})
+	if err != nil {
+		log.Printf("[cron] refresh hot posts cache failed: %v\n", err)
+		return
+	}
+	if ran {
+		log.Println("[cron] hot posts cache refreshed")
+	}
+}
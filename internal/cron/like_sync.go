@@ -9,36 +9,52 @@ import (
 	"gorm.io/gorm"
 )
 
+// held by the replica currently syncing, so each tick writes MySQL once cluster-wide
+const likeSyncLockKey = "lock:cron:like_sync"
+
 // periodically sync Redis like_count back to MySQL
 func StartLikeSync(db *gorm.DB) {
 	ticker := time.NewTicker(10 * time.Second)
 
 	go func() {
 		for range ticker.C {
-			keys, err := dao.GetAllLikeCountKeys()
-			if err != nil {
-				log.Println("[cron] failed to fetch like_count keys:", err)
-				continue
+			if _, err := dao.WithLock(likeSyncLockKey, 9*time.Second, func() error {
+				syncLikeCounts(db)
+				return nil
+			}); err != nil {
+				log.Println("[cron] failed to take like sync lock:", err)
 			}
+		}
+	}()
+}
 
-			for _, key := range keys {
-				postID, err := dao.ExtractPostID(key)
-				if err != nil || postID == 0 {
-					continue
-				}
-
-				count, err := dao.GetLikeCountFromRedis(key)
-				if err != nil {
-					continue
-				}
+func syncLikeCounts(db *gorm.DB) {
+	keys, err := dao.GetAllLikeCountKeys()
+	if err != nil {
+		log.Println("[cron] failed to fetch like_count keys:", err)
+		return
+	}
+
+	for _, key := range keys {
+		postID, err := dao.ExtractPostID(key)
+		if err != nil || postID == 0 {
+			continue
+		}
 
-				if err := dao.UpdatePostLikeCount(db, postID, count); err != nil {
-					log.Printf("[cron] failed to update MySQL (post_id=%d): %v\n", postID, err)
-					continue
-				}
+		count, err := dao.GetLikeCountFromRedis(key)
+		if err != nil {
+			continue
+		}
 
-				log.Printf("[cron] synced post_id = %d, like_id = %d\n", postID, count)
-			}
+		changed, err := dao.UpdatePostLikeCount(db, postID, count)
+		if err != nil {
+			log.Printf("[cron] failed to update MySQL (post_id=%d): %v\n", postID, err)
+			continue
 		}
-	}()
+		if !changed {
+			continue
+		}
+
+		log.Printf("[cron] synced post_id = %d, like_id = %d\n", postID, count)
+	}
 }
@@ -0,0 +1,34 @@
+package cron
+
+import (
+	"log"
+	"time"
+
+	"minifeed/internal/dao"
+
+	"gorm.io/gorm"
+)
+
+// keep the embedded search index in step with MySQL; every replica holds its
+// own index, so there is no lock here
+func StartSearchCatchUp(db *gorm.DB) {
+	ticker := time.NewTicker(1 * time.Minute)
+
+	go func() {
+		catchUpSearch(db)
+		for range ticker.C {
+			catchUpSearch(db)
+		}
+	}()
+}
+
+func catchUpSearch(db *gorm.DB) {
+	n, err := dao.CatchUpSearchIndex(db)
+	if err != nil {
+		log.Printf("[cron] catch up search index failed after %d posts: %v\n", n, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[cron] %d posts added to the search index\n", n)
+	}
+}
@@ -0,0 +1,50 @@
+package cron
+
+import (
+	"log"
+	"time"
+
+	"minifeed/internal/dao"
+
+	"gorm.io/gorm"
+)
+
+// held by the replica currently precomputing suggestions; renewed after every
+// batch, so it only lapses if that replica stalls or dies
+const (
+	suggestionRefreshLockKey = "lock:cron:suggestion"
+	suggestionRefreshLockTTL = 5 * time.Minute
+)
+
+// precompute "who to follow" suggestions into Redis periodically
+func StartSuggestionRefresh(db *gorm.DB) {
+	ticker := time.NewTicker(1 * time.Hour)
+
+	go func() {
+		refreshSuggestions(db)
+		for range ticker.C {
+			refreshSuggestions(db)
+		}
+	}()
+}
+
+func refreshSuggestions(db *gorm.DB) {
+	lock, ok, err := dao.TryLock(suggestionRefreshLockKey, suggestionRefreshLockTTL)
+	if err != nil {
+		log.Printf("[cron] refresh suggestions: take lock failed: %v\n", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer lock.Unlock()
+
+	n, err := dao.RefreshSuggestions(db, func() error {
+		return lock.Refresh(suggestionRefreshLockTTL)
+	})
+	if err != nil {
+		log.Printf("[cron] refresh suggestions failed after %d users: %v\n", n, err)
+		return
+	}
+	log.Printf("[cron] suggestions refreshed for %d users\n", n)
+}
@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"minifeed/internal/config"
+)
+
+// affinity:{uid} is a hash of author id -> likes uid gave that author's posts
+const (
+	affinityPrefix = "affinity:"
+	affinityTTL    = 30 * 24 * time.Hour
+)
+
+var affinityCtx = context.Background()
+
+func affinityKey(userID uint) string {
+	return fmt.Sprintf("%s%d", affinityPrefix, userID)
+}
+
+// records a like (delta 1) or unlike (delta -1) of one of authorID's posts
+func IncrAuthorAffinity(userID, authorID uint, delta int64) {
+	key := affinityKey(userID)
+	field := strconv.FormatUint(uint64(authorID), 10)
+
+	pipe := config.Rdb.Pipeline()
+	pipe.HIncrBy(affinityCtx, key, field, delta)
+	pipe.Expire(affinityCtx, key, affinityTTL)
+	_, _ = pipe.Exec(affinityCtx)
+}
+
+// per-author affinity of a user, log-damped so a handful of authors cannot dominate
+func GetAuthorAffinity(userID uint) (map[uint]float64, error) {
+	raw, err := config.Rdb.HGetAll(affinityCtx, affinityKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	affinity := make(map[uint]float64, len(raw))
+	for field, v := range raw {
+		id64, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			continue
+		}
+		affinity[uint(id64)] = math.Log1p(float64(n))
+	}
+	return affinity, nil
+}
@@ -2,57 +2,364 @@ package dao
 
 import (
 	"fmt"
-	"minifeed/internal/model"
+	"log"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 
+	"minifeed/internal/metrics"
+	"minifeed/internal/model"
+
 	"github.com/bits-and-blooms/bloom/v3"
 	"gorm.io/gorm"
 )
 
+const (
+	BloomBackendMemory = "memory"
+	BloomBackendRedis  = "redis"
+
+	bloomFPRate = 0.001
+	// each new layer holds bloomGrowth times more items at bloomTightening times the fp rate,
+	// which keeps the compound fp rate below bloomFPRate / (1 - bloomTightening)
+	bloomGrowth     = 2
+	bloomTightening = 0.5
+
+	bloomLoadBatch = 1000
+)
+
+// membership filter: Test never reports false for a key that was added
+type BloomFilter interface {
+	Add(key string) error
+	Test(key string) (bool, error)
+	Stats() (BloomStats, error)
+}
+
+type BloomStats struct {
+	Layers    int
+	Items     uint
+	FillRatio float64 // set bits / total bits over all layers
+	FPRate    float64 // estimated from each layer's fill ratio
+}
+
+// capacity and bitmap size of the i-th layer of a scalable filter
+func bloomLayerParams(base uint, i int) (capacity, m, k uint) {
+	capacity = base * uint(math.Pow(bloomGrowth, float64(i)))
+	m, k = bloom.EstimateParameters(capacity, bloomFPRate*math.Pow(bloomTightening, float64(i)))
+	return capacity, m, k
+}
+
+func newBloomStats(items uint, fills []float64, ks []uint) BloomStats {
+	var sum float64
+	miss := 1.0
+	for i, fill := range fills {
+		sum += fill
+		miss *= 1 - math.Pow(fill, float64(ks[i]))
+	}
+
+	return BloomStats{
+		Layers:    len(fills),
+		Items:     items,
+		FillRatio: sum / float64(len(fills)),
+		FPRate:    1 - miss,
+	}
+}
+
+const (
+	BloomPosts     = "post"
+	BloomUsers     = "user"
+	BloomUsernames = "username"
+)
+
+// streams the keys of rows with id > afterID into add, returning the largest id seen
+type BloomSource func(db *gorm.DB, afterID uint, add func(keys []string) error) (uint, error)
+
+// one filter of the registry
+type namedBloom struct {
+	name   string
+	base   uint
+	source BloomSource
+
+	mu     sync.RWMutex
+	filter BloomFilter
+}
+
+func (b *namedBloom) get() BloomFilter {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.filter
+}
+
+func (b *namedBloom) set(f BloomFilter) {
+	b.mu.Lock()
+	b.filter = f
+	b.mu.Unlock()
+}
+
 var (
-	postBloom   *bloom.BloomFilter
-	postBloomMu sync.RWMutex
+	bloomBackend    = BloomBackendMemory
+	bloomRegistry   = make(map[string]*namedBloom)
+	bloomRegistryMu sync.RWMutex
 )
 
-func InitPostBloom(db *gorm.DB, nEstimates uint) error {
-	postBloomMu.Lock()
-	defer postBloomMu.Unlock()
+func init() {
+	RegisterBloom(BloomPosts, 10000, loadPostIDs)
+	RegisterBloom(BloomUsers, 10000, loadUserIDs)
+	RegisterBloom(BloomUsernames, 10000, loadUsernames)
+}
 
-	postBloom = bloom.NewWithEstimates(nEstimates, 0.001)
+// adds a filter to the registry; nEstimates sizes its first layer, later layers grow as needed
+func RegisterBloom(name string, nEstimates uint, source BloomSource) {
+	bloomRegistryMu.Lock()
+	defer bloomRegistryMu.Unlock()
 
-	var posts []model.Post
-	if err := db.Select("id").Find(&posts).Error; err != nil {
-		return err
+	bloomRegistry[name] = &namedBloom{name: name, base: nEstimates, source: source}
+}
+
+func lookupBloom(name string) *namedBloom {
+	bloomRegistryMu.RLock()
+	defer bloomRegistryMu.RUnlock()
+	return bloomRegistry[name]
+}
+
+func registeredBlooms() []*namedBloom {
+	bloomRegistryMu.RLock()
+	defer bloomRegistryMu.RUnlock()
+
+	blooms := make([]*namedBloom, 0, len(bloomRegistry))
+	for _, b := range bloomRegistry {
+		blooms = append(blooms, b)
 	}
+	return blooms
+}
 
-	for _, p := range posts {
-		idStr := fmt.Sprintf("%d", p.ID)
-		postBloom.AddString(idStr)
+// builds every registered filter; backend "redis" keeps them in Redis bitmaps
+// shared by every replica, and filters another replica already built are attached
+func InitBlooms(db *gorm.DB, backend string) error {
+	bloomRegistryMu.Lock()
+	if backend == BloomBackendRedis {
+		bloomBackend = BloomBackendRedis
 	}
+	bloomRegistryMu.Unlock()
 
+	for _, b := range registeredBlooms() {
+		if backend == BloomBackendRedis {
+			shared := newRedisBloom(b.name, b.base)
+			if shared.built() {
+				_, _ = shared.Stats() // syncs the known layer count
+				b.set(shared)
+				continue
+			}
+		}
+
+		if err := rebuildBloom(db, b); err != nil {
+			return fmt.Errorf("bloom %s: %w", b.name, err)
+		}
+	}
 	return nil
+}
 
+// rebuilds every registered filter from MySQL
+func RebuildBlooms(db *gorm.DB) error {
+	for _, b := range registeredBlooms() {
+		if err := rebuildBloom(db, b); err != nil {
+			return fmt.Errorf("bloom %s: %w", b.name, err)
+		}
+	}
+	return nil
 }
 
-func AddPostToBloom(postID uint) {
-	postBloomMu.RLock()
-	defer postBloomMu.RUnlock()
+// rebuilds a filter from MySQL and swaps it in; rows created while
+// the rebuild ran are added again afterwards so none is lost
+func rebuildBloom(db *gorm.DB, b *namedBloom) error {
+	bloomRegistryMu.RLock()
+	backend := bloomBackend
+	bloomRegistryMu.RUnlock()
 
-	if postBloom == nil {
-		return
+	if backend == BloomBackendRedis {
+		shared, ok := b.get().(*redisBloom)
+		if !ok {
+			shared = newRedisBloom(b.name, b.base)
+		}
+
+		built, err := shared.nextVersion()
+		if err != nil {
+			return err
+		}
+		var count uint
+		maxID, err := b.source(db, 0, func(keys []string) error {
+			return built.addBatch(keys, &count)
+		})
+		if err != nil {
+			return err
+		}
+		if err := shared.activate(built); err != nil {
+			return err
+		}
+		b.set(shared)
+
+		_, err = b.source(db, maxID, addKeys(shared))
+		return err
+	}
+
+	built := newMemoryBloom(b.base)
+	maxID, err := b.source(db, 0, addKeys(built))
+	if err != nil {
+		return err
 	}
+	b.set(built)
 
-	postBloom.AddString(fmt.Sprintf("%d", postID))
+	_, err = b.source(db, maxID, addKeys(built))
+	return err
+}
 
+func addKeys(f BloomFilter) func(keys []string) error {
+	return func(keys []string) error {
+		for _, key := range keys {
+			if err := f.Add(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 }
 
-func PostMayExist(postID uint) bool {
-	postBloomMu.RLock()
-	defer postBloomMu.RUnlock()
+// adds a key to a named filter
+func BloomAdd(name, key string) {
+	b := lookupBloom(name)
+	if b == nil {
+		return
+	}
+	f := b.get()
+	if f == nil {
+		return
+	}
+
+	if err := f.Add(key); err != nil {
+		log.Printf("[warn] add %q to bloom %s failed: %v\n", key, name, err)
+	}
+}
+
+// false means the key was never added; true may be a false positive
+func BloomMayContain(name, key string) bool {
+	b := lookupBloom(name)
+	if b == nil {
+		return true
+	}
+	f := b.get()
+	if f == nil {
+		return true
+	}
 
-	if postBloom == nil {
+	ok, err := f.Test(key)
+	if err != nil {
 		return true
 	}
-	return postBloom.TestString(fmt.Sprintf("%d", postID))
+	return ok
+}
+
+// streams post ids greater than afterID into add, returning the largest id seen
+func loadPostIDs(db *gorm.DB, afterID uint, add func(keys []string) error) (uint, error) {
+	maxID := afterID
+	var batch []model.Post
+
+	err := db.Select("id").Where("id > ?", afterID).
+		FindInBatches(&batch, bloomLoadBatch, func(tx *gorm.DB, _ int) error {
+			keys := make([]string, len(batch))
+			for i, p := range batch {
+				keys[i] = strconv.FormatUint(uint64(p.ID), 10)
+				maxID = p.ID
+			}
+			return add(keys)
+		}).Error
+
+	return maxID, err
+}
+
+func loadUserIDs(db *gorm.DB, afterID uint, add func(keys []string) error) (uint, error) {
+	return loadUsers(db, afterID, add, func(u model.User) string {
+		return strconv.FormatUint(uint64(u.ID), 10)
+	})
+}
+
+func loadUsernames(db *gorm.DB, afterID uint, add func(keys []string) error) (uint, error) {
+	return loadUsers(db, afterID, add, func(u model.User) string {
+		return usernameKey(u.Username)
+	})
+}
+
+func loadUsers(db *gorm.DB, afterID uint, add func(keys []string) error, key func(model.User) string) (uint, error) {
+	maxID := afterID
+	var batch []model.User
+
+	err := db.Select("id", "username").Where("id > ?", afterID).
+		FindInBatches(&batch, bloomLoadBatch, func(tx *gorm.DB, _ int) error {
+			keys := make([]string, len(batch))
+			for i, u := range batch {
+				keys[i] = key(u)
+				maxID = u.ID
+			}
+			return add(keys)
+		}).Error
+
+	return maxID, err
+}
+
+// usernames are unique case-insensitively in MySQL, so the filter is too
+func usernameKey(username string) string {
+	return strings.ToLower(username)
+}
+
+func AddPostToBloom(postID uint) {
+	BloomAdd(BloomPosts, fmt.Sprintf("%d", postID))
+}
+
+// false only when the post surely does not exist; see bloomRulesOut
+func PostMayExist(postID uint) bool {
+	return !bloomRulesOut(BloomPosts, fmt.Sprintf("%d", postID))
+}
+
+// adds a new user to the user id and username filters
+func AddUserToBloom(u *model.User) {
+	BloomAdd(BloomUsers, fmt.Sprintf("%d", u.ID))
+	BloomAdd(BloomUsernames, usernameKey(u.Username))
+}
+
+// false only when the user surely does not exist; see bloomRulesOut
+func UserMayExist(userID uint) bool {
+	return !bloomRulesOut(BloomUsers, fmt.Sprintf("%d", userID))
+}
+
+func UsernameMayExist(username string) bool {
+	return !bloomRulesOut(BloomUsernames, usernameKey(username))
+}
+
+// a miss proves absence only in the shared filter; a per-replica one misses
+// keys added through other replicas until its next rebuild, so callers
+// have to ask MySQL instead
+func bloomRulesOut(name, key string) bool {
+	bloomRegistryMu.RLock()
+	shared := bloomBackend == BloomBackendRedis
+	bloomRegistryMu.RUnlock()
+
+	return shared && !BloomMayContain(name, key)
+}
+
+// publishes fill ratio and estimated false-positive rate of every filter
+func UpdateBloomMetrics() {
+	for _, b := range registeredBlooms() {
+		f := b.get()
+		if f == nil {
+			continue
+		}
 
+		st, err := f.Stats()
+		if err != nil {
+			continue
+		}
+		metrics.BloomFillRatio.WithLabelValues(b.name).Set(st.FillRatio)
+		metrics.BloomFPRate.WithLabelValues(b.name).Set(st.FPRate)
+		metrics.BloomLayers.WithLabelValues(b.name).Set(float64(st.Layers))
+		metrics.BloomItems.WithLabelValues(b.name).Set(float64(st.Items))
+	}
 }
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"sync"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// scalable Bloom filter kept in process memory: when the newest layer reaches its
+// capacity a larger layer with a tighter false-positive rate is appended
+type memoryBloom struct {
+	mu     sync.RWMutex
+	base   uint
+	layers []*bloom.BloomFilter
+	count  uint // items in the newest layer
+	total  uint
+}
+
+func newMemoryBloom(base uint) *memoryBloom {
+	_, m, k := bloomLayerParams(base, 0)
+	return &memoryBloom{
+		base:   base,
+		layers: []*bloom.BloomFilter{bloom.New(m, k)},
+	}
+}
+
+func (f *memoryBloom) Add(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, l := range f.layers {
+		if l.TestString(key) {
+			return nil
+		}
+	}
+
+	capacity, _, _ := bloomLayerParams(f.base, len(f.layers)-1)
+	if f.count >= capacity {
+		_, m, k := bloomLayerParams(f.base, len(f.layers))
+		f.layers = append(f.layers, bloom.New(m, k))
+		f.count = 0
+	}
+
+	f.layers[len(f.layers)-1].AddString(key)
+	f.count++
+	f.total++
+	return nil
+}
+
+func (f *memoryBloom) Test(key string) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, l := range f.layers {
+		if l.TestString(key) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *memoryBloom) Stats() (BloomStats, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	fills := make([]float64, len(f.layers))
+	ks := make([]uint, len(f.layers))
+	for i, l := range f.layers {
+		fills[i] = float64(l.BitSet().Count()) / float64(l.Cap())
+		ks[i] = l.K()
+	}
+
+	return newBloomStats(f.total, fills, ks), nil
+}
@@ -0,0 +1,279 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"minifeed/internal/config"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis bitmap layout, shared by every replica:
+//
+//	bloom:{name}:version          active version
+//	bloom:{name}:seq              last version handed out to a rebuild
+//	bloom:{name}:v{n}:meta        hash {layers, count, total}
+//	bloom:{name}:v{n}:{layer}     bitmap of one layer
+//
+// bit positions only depend on the layer index, so callers compute them locally
+// and the scripts reject a call made with a stale layer count (returning -layers)
+var bloomAddScript = redis.NewScript(`
+local prefix = ARGV[1]
+local v = ARGV[2]
+if v == "" then
+	v = redis.call("GET", prefix .. ":version") or "0"
+end
+local meta = prefix .. ":v" .. v .. ":meta"
+if redis.call("EXISTS", meta) == 0 then
+	return tonumber(ARGV[3])
+end
+local layers = tonumber(redis.call("HGET", meta, "layers"))
+if layers ~= tonumber(ARGV[3]) then
+	return -layers
+end
+local layer = prefix .. ":v" .. v .. ":" .. (layers - 1)
+for i = 5, #ARGV do
+	redis.call("SETBIT", layer, ARGV[i], 1)
+end
+redis.call("HINCRBY", meta, "total", 1)
+if redis.call("HINCRBY", meta, "count", 1) >= tonumber(ARGV[4]) then
+	redis.call("HSET", meta, "layers", layers + 1, "count", 0)
+end
+return layers
+`)
+
+// ARGV: prefix, layers, then per layer: k, followed by k positions;
+// a filter that was never built (or was flushed) answers "maybe"
+var bloomTestScript = redis.NewScript(`
+local prefix = ARGV[1]
+local v = redis.call("GET", prefix .. ":version") or "0"
+local meta = prefix .. ":v" .. v .. ":meta"
+if redis.call("EXISTS", meta) == 0 then
+	return 1
+end
+local layers = tonumber(redis.call("HGET", meta, "layers"))
+if layers > tonumber(ARGV[2]) then
+	return -layers
+end
+local idx = 3
+for l = 0, layers - 1 do
+	local k = tonumber(ARGV[idx])
+	local hit = 1
+	for i = idx + 1, idx + k do
+		if redis.call("GETBIT", prefix .. ":v" .. v .. ":" .. l, ARGV[i]) == 0 then
+			hit = 0
+			break
+		end
+	end
+	if hit == 1 then
+		return 1
+	end
+	idx = idx + k + 1
+end
+return 0
+`)
+
+// old versions are kept for a while so replicas still reading them are not cut off
+const bloomOldVersionTTL = 10 * time.Minute
+
+type redisBloom struct {
+	prefix  string
+	base    uint
+	version string // "" follows the active version
+
+	mu     sync.RWMutex
+	layers int // last known layer count
+}
+
+var bloomCtx = context.Background()
+
+func newRedisBloom(name string, base uint) *redisBloom {
+	return &redisBloom{prefix: "bloom:" + name, base: base, layers: 1}
+}
+
+func (f *redisBloom) knownLayers() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.layers
+}
+
+func (f *redisBloom) setLayers(n int) {
+	f.mu.Lock()
+	f.layers = n
+	f.mu.Unlock()
+}
+
+func bloomPositions(key string, m, k uint) []interface{} {
+	locs := bloom.Locations([]byte(key), k)
+	pos := make([]interface{}, len(locs))
+	for i, l := range locs {
+		pos[i] = l % uint64(m)
+	}
+	return pos
+}
+
+func (f *redisBloom) addArgs(key string, layers int) []interface{} {
+	capacity, m, k := bloomLayerParams(f.base, layers-1)
+	args := []interface{}{f.prefix, f.version, layers, capacity}
+	return append(args, bloomPositions(key, m, k)...)
+}
+
+func (f *redisBloom) Add(key string) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		layers := f.knownLayers()
+		n, err := bloomAddScript.Run(bloomCtx, config.Rdb, nil, f.addArgs(key, layers)...).Int()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		f.setLayers(-n)
+	}
+	return fmt.Errorf("bloom %s: layer count kept changing", f.prefix)
+}
+
+// bulk insert for rebuilds; the builder is the only writer of its version,
+// so the layer count is tracked locally instead of round-tripping
+func (f *redisBloom) addBatch(keys []string, count *uint) error {
+	if err := bloomAddScript.Load(bloomCtx, config.Rdb).Err(); err != nil {
+		return err
+	}
+
+	pipe := config.Rdb.Pipeline()
+	for _, key := range keys {
+		layers := f.knownLayers()
+		bloomAddScript.EvalSha(bloomCtx, pipe, nil, f.addArgs(key, layers)...)
+
+		*count++
+		capacity, _, _ := bloomLayerParams(f.base, layers-1)
+		if *count >= capacity {
+			f.setLayers(layers + 1)
+			*count = 0
+		}
+	}
+	_, err := pipe.Exec(bloomCtx)
+	return err
+}
+
+func (f *redisBloom) Test(key string) (bool, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		layers := f.knownLayers()
+		args := []interface{}{f.prefix, layers}
+		for i := 0; i < layers; i++ {
+			_, m, k := bloomLayerParams(f.base, i)
+			args = append(args, k)
+			args = append(args, bloomPositions(key, m, k)...)
+		}
+
+		n, err := bloomTestScript.Run(bloomCtx, config.Rdb, nil, args...).Int()
+		if err != nil {
+			return true, err
+		}
+		if n >= 0 {
+			return n == 1, nil
+		}
+		f.setLayers(-n)
+	}
+	return true, fmt.Errorf("bloom %s: layer count kept changing", f.prefix)
+}
+
+func (f *redisBloom) activeVersion() (string, error) {
+	if f.version != "" {
+		return f.version, nil
+	}
+	v, err := config.Rdb.Get(bloomCtx, f.prefix+":version").Result()
+	if err == redis.Nil {
+		return "0", nil
+	}
+	return v, err
+}
+
+// reports whether some replica has already activated a version
+func (f *redisBloom) built() bool {
+	n, err := config.Rdb.Exists(bloomCtx, f.prefix+":version").Result()
+	return err == nil && n == 1
+}
+
+func (f *redisBloom) Stats() (BloomStats, error) {
+	v, err := f.activeVersion()
+	if err != nil {
+		return BloomStats{}, err
+	}
+
+	meta, err := config.Rdb.HGetAll(bloomCtx, fmt.Sprintf("%s:v%s:meta", f.prefix, v)).Result()
+	if err != nil {
+		return BloomStats{}, err
+	}
+	layers, _ := strconv.Atoi(meta["layers"])
+	if layers == 0 {
+		layers = 1
+	}
+	total, _ := strconv.ParseUint(meta["total"], 10, 64)
+	f.setLayers(layers)
+
+	pipe := config.Rdb.Pipeline()
+	counts := make([]*redis.IntCmd, layers)
+	for i := 0; i < layers; i++ {
+		counts[i] = pipe.BitCount(bloomCtx, fmt.Sprintf("%s:v%s:%d", f.prefix, v, i), nil)
+	}
+	if _, err := pipe.Exec(bloomCtx); err != nil {
+		return BloomStats{}, err
+	}
+
+	fills := make([]float64, layers)
+	ks := make([]uint, layers)
+	for i := 0; i < layers; i++ {
+		_, m, k := bloomLayerParams(f.base, i)
+		fills[i] = float64(counts[i].Val()) / float64(m)
+		ks[i] = k
+	}
+
+	return newBloomStats(uint(total), fills, ks), nil
+}
+
+// reserves a fresh version for a rebuild; it stays invisible until activated
+func (f *redisBloom) nextVersion() (*redisBloom, error) {
+	seq, err := config.Rdb.Incr(bloomCtx, f.prefix+":seq").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	meta := fmt.Sprintf("%s:v%d:meta", f.prefix, seq)
+	if err := config.Rdb.HSet(bloomCtx, meta, "layers", 1, "count", 0, "total", 0).Err(); err != nil {
+		return nil, err
+	}
+
+	return &redisBloom{
+		prefix:  f.prefix,
+		base:    f.base,
+		version: strconv.FormatInt(seq, 10),
+		layers:  1,
+	}, nil
+}
+
+// points every replica at the rebuilt version and lets the old one expire
+func (f *redisBloom) activate(built *redisBloom) error {
+	old, err := f.activeVersion()
+	if err != nil {
+		return err
+	}
+
+	pipe := config.Rdb.TxPipeline()
+	pipe.Set(bloomCtx, f.prefix+":version", built.version, 0)
+	pipe.Expire(bloomCtx, fmt.Sprintf("%s:v%s:meta", f.prefix, old), bloomOldVersionTTL)
+	for i := 0; i < f.knownLayers(); i++ {
+		pipe.Expire(bloomCtx, fmt.Sprintf("%s:v%s:%d", f.prefix, old, i), bloomOldVersionTTL)
+	}
+	if _, err := pipe.Exec(bloomCtx); err != nil {
+		return err
+	}
+
+	f.setLayers(built.knownLayers())
+	return nil
+}
@@ -2,12 +2,13 @@ package dao
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"strconv"
 	"sync"
 	"time"
 
+	"minifeed/internal/cache"
 	"minifeed/internal/config"
 	"minifeed/internal/model"
 
@@ -15,87 +16,141 @@ import (
 )
 
 const (
-	hotPostsKey      = "hot:posts"
+	hotPostsPrefix   = "hot:posts:"
 	hotPostsCacheTTL = 60 * time.Second
 	hotPostsCacheTop = 100
-	hotPostsEmptyKey = "hot:posts:empty"
+	// posts kept in each window's ranking ZSet
+	hotCandidatePool = 1000
+
+	DefaultHotWindow = "24h"
 )
 
+// selectable ranking windows for the hot feed
+var HotWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
 var (
-	hotCtx     = context.Background()
-	hotBuildMu sync.Mutex
+	hotCtx = context.Background()
+
+	// L1 copy of each window's hot id list, short-lived so a missed invalidation heals quickly
+	hotL1 = newLocalCache[[]uint]("hot", len(HotWindows), 5*time.Second)
+
+	hotCacheOnce sync.Once
+	hotCache     *cache.Cache[string, []uint]
 )
 
-// applies a "double-delete" strategy for the hot posts cache
-func InvalidateHotPostCache() {
+func hotPostsKey(window string) string {
+	return hotPostsPrefix + window
+}
 
-	_ = config.Rdb.Del(hotCtx, hotPostsKey).Err()
+// the ranked id list of each window, built from the ranking ZSet
+func hotPostsCache() *cache.Cache[string, []uint] {
+	hotCacheOnce.Do(func() {
+		hotCache = cache.New[string, []uint]("hot", config.Rdb, hotPostsKey, cache.Options{
+			TTL:         hotPostsCacheTTL,
+			Jitter:      30 * time.Second,
+			NegativeTTL: 10 * time.Second,
+			Beta:        1,
+			Locker:      redisLocker{},
+			StaleTTL:    10 * time.Minute,
+			OnDelete:    publishInvalidation,
+		})
+	})
+	return hotCache
+}
 
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		_ = config.Rdb.Del(hotCtx, hotPostsKey).Err()
-	}()
+func hotWindowList() []string {
+	windows := make([]string, 0, len(HotWindows))
+	for w := range HotWindows {
+		windows = append(windows, w)
+	}
+	return windows
+}
 
+// applies a "double-delete" strategy for the hot posts cache
+func InvalidateHotPostCache() {
+	_ = hotPostsCache().Invalidate(hotCtx, hotWindowList()...)
 }
 
 // delete before write
 func DelHotPostsCache() {
-	_ = config.Rdb.Del(hotCtx, hotPostsKey).Err()
+	_ = hotPostsCache().Del(hotCtx, hotWindowList()...)
 }
 
 // delete after write
 func DelHotPostsCacheAsync() {
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		_ = config.Rdb.Del(hotCtx, hotPostsKey).Err()
-	}()
+	hotPostsCache().DelAsync(hotWindowList()...)
 }
 
-// rebuild the hot posts cache periodically
+// rebuild the hot posts cache of every window periodically
 func RefreshHotPostsCache(db *gorm.DB) error {
-	_, err := buildHotPostsCache(db)
-	return err
-}
-
-// loads hot posts from MySQL and writes their IDs to Redis
-func buildHotPostsCache(db *gorm.DB) ([]model.Post, error) {
-	var posts []model.Post
-	if err := db.Order("like_count DESC").Limit(hotPostsCacheTop).Find(&posts).Error; err != nil {
-		return nil, err
+	for _, w := range hotWindowList() {
+		_, err := hotPostsCache().Refresh(hotCtx, w, hotPostsLoader(db))
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return err
+		}
+		publishInvalidation(hotPostsKey(w))
 	}
+	return nil
+}
 
-	pipe := config.Rdb.TxPipeline()
-	pipe.Del(hotCtx, hotPostsEmptyKey)
-
-	if len(posts) == 0 {
-		pipe.Del(hotCtx, hotPostsKey)
-		pipe.Set(hotCtx, hotPostsEmptyKey, "1", 10*time.Second)
-
-		if _, err := pipe.Exec(hotCtx); err != nil {
+// an empty ranking is reported as cache.ErrNotFound so it is negatively cached
+func hotPostsLoader(db *gorm.DB) cache.Loader[string, []uint] {
+	return func(ctx context.Context, window string) ([]uint, error) {
+		posts, err := rankHotPosts(db, window)
+		if err != nil {
 			return nil, err
 		}
-		return []model.Post{}, nil
+		if len(posts) == 0 {
+			return nil, cache.ErrNotFound
+		}
 
+		ids := make([]uint, 0, len(posts))
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		return ids, nil
 	}
+}
 
-	pipe.Del(hotCtx, hotPostsKey)
-
-	for _, p := range posts {
-		pipe.RPush(hotCtx, hotPostsKey, fmt.Sprintf("%d", p.ID))
+// reads the window's ranking and applies the author-diversity pass
+func rankHotPosts(db *gorm.DB, window string) ([]model.Post, error) {
+	zs, err := config.Rdb.ZRevRangeWithScores(hotCtx, hotRankKey(window), 0, hotCandidatePool-1).Result()
+	if err != nil {
+		return nil, err
 	}
 
-	jitter := time.Duration(rand.Intn(30)) * time.Second
-	pipe.Expire(hotCtx, hotPostsKey, hotPostsCacheTTL+jitter)
+	ids := make([]uint, 0, len(zs))
+	scores := make(map[uint]float64, len(zs))
+	for _, z := range zs {
+		id64, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		ids = append(ids, uint(id64))
+		scores[uint(id64)] = z.Score
+	}
 
-	if _, err := pipe.Exec(hotCtx); err != nil {
+	posts, err := GetPostsByIDs(db, ids)
+	if err != nil {
 		return nil, err
 	}
 
-	return posts, nil
+	ranked := diversifyByAuthor(posts, scores)
+	if len(ranked) > hotPostsCacheTop {
+		ranked = ranked[:hotPostsCacheTop]
+	}
+	return ranked, nil
 }
 
-// reads hot posts
-func GetHotPosts(db *gorm.DB, limit int) ([]model.Post, error) {
+// reads hot posts of a window
+func GetHotPosts(db *gorm.DB, window string, limit int) ([]model.Post, error) {
+	if _, ok := HotWindows[window]; !ok {
+		window = DefaultHotWindow
+	}
 	if limit <= 0 {
 		limit = 10
 	}
@@ -103,72 +158,29 @@ func GetHotPosts(db *gorm.DB, limit int) ([]model.Post, error) {
 		limit = hotPostsCacheTop
 	}
 
-	empty, err := config.Rdb.Exists(hotCtx, hotPostsEmptyKey).Result()
-	if err == nil && empty == 1 {
-		return []model.Post{}, nil
-	}
-
-	idStrs, err := config.Rdb.LRange(hotCtx, hotPostsKey, 0, int64(limit-1)).Result()
-	if err != nil {
-		idStrs = nil
-	}
-
-	if len(idStrs) == 0 {
-		hotBuildMu.Lock()
-		defer hotBuildMu.Unlock()
-
-		idStrs, _ = config.Rdb.LRange(hotCtx, hotPostsKey, 0, int64(limit-1)).Result()
-		if len(idStrs) == 0 {
-			empty, err := config.Rdb.Exists(hotCtx, hotPostsEmptyKey).Result()
-			if err == nil && empty == 1 {
-				return []model.Post{}, nil
-			}
-
-			if _, err := buildHotPostsCache(db); err != nil {
-				var posts []model.Post
-				if err := db.Order("like_count DESC").Order("id DESC").Limit(limit).Find(&posts).Error; err != nil {
-					return nil, err
-				}
-				return posts, nil
-			}
-
-			idStrs, _ = config.Rdb.LRange(hotCtx, hotPostsKey, 0, int64(limit-1)).Result()
-			if len(idStrs) == 0 {
-				return []model.Post{}, nil
-			}
-		}
+	key := hotPostsKey(window)
 
+	if ids, ok := hotL1.Get(key); ok {
+		return GetPostsByIDs(db, headIDs(ids, limit))
 	}
 
-	ids := make([]uint, 0, len(idStrs))
-	for _, s := range idStrs {
-		id64, err := strconv.ParseUint(s, 10, 64)
-		if err != nil || id64 == 0 {
-			continue
-		}
-		ids = append(ids, uint(id64))
-	}
-	if len(ids) == 0 {
+	ids, err := hotPostsCache().Get(hotCtx, window, hotPostsLoader(db))
+	if errors.Is(err, cache.ErrNotFound) {
+		hotL1.Set(key, []uint{})
 		return []model.Post{}, nil
 	}
-
-	var posts []model.Post
-	if err := db.Where("id IN ?", ids).Find(&posts).Error; err != nil {
+	if err != nil {
 		return nil, err
 	}
+	hotL1.Set(key, ids)
 
-	m := make(map[uint]model.Post, len(posts))
-	for _, p := range posts {
-		m[p.ID] = p
-	}
-
-	ordered := make([]model.Post, 0, len(ids))
-	for _, id := range ids {
-		if p, ok := m[id]; ok {
-			ordered = append(ordered, p)
-		}
-	}
+	return GetPostsByIDs(db, headIDs(ids, limit))
 
-	return ordered, nil
+}
 
+func headIDs(ids []uint, limit int) []uint {
+	if len(ids) > limit {
+		return ids[:limit]
+	}
+	return ids
 }
@@ -0,0 +1,190 @@
+package dao
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// hot:rank:{window} is a ZSet of post ids scored by hotScorer, kept up to date on
+// every like and periodically decayed, so the hot feed never scans MySQL
+const hotRankPrefix = "hot:rank:"
+
+func hotRankKey(window string) string {
+	return hotRankPrefix + window
+}
+
+// windows a post created at createdAt still belongs to
+func hotWindowsOf(createdAt, now time.Time) []string {
+	windows := make([]string, 0, len(HotWindows))
+	for w, d := range HotWindows {
+		if now.Sub(createdAt) <= d {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// adds a new post to the ranking of every window
+func AddPostToHotRank(p model.Post) {
+	UpdateHotRankLikes(p, int64(p.LikeCount))
+}
+
+// re-scores a post after its like count changed
+func UpdateHotRankLikes(p model.Post, likes int64) {
+	now := time.Now()
+	score := hotScorer.Score(newHotCandidate(p, likes), now)
+
+	pipe := config.Rdb.Pipeline()
+	for _, w := range hotWindowsOf(p.CreatedAt, now) {
+		pipe.ZAdd(hotCtx, hotRankKey(w), redis.Z{Score: score, Member: p.ID})
+	}
+	_, _ = pipe.Exec(hotCtx)
+}
+
+// drops a removed post from the ranking of every window
+func RemovePostFromHotRank(postID uint) {
+	pipe := config.Rdb.Pipeline()
+	for w := range HotWindows {
+		pipe.ZRem(hotCtx, hotRankKey(w), postID)
+	}
+	_, _ = pipe.Exec(hotCtx)
+}
+
+// drops the rankings of every window and seeds them again from MySQL
+func RebuildHotRanks(db *gorm.DB) error {
+	keys := make([]string, 0, len(HotWindows))
+	for w := range HotWindows {
+		keys = append(keys, hotRankKey(w))
+	}
+	if err := config.Rdb.Del(hotCtx, keys...).Err(); err != nil {
+		return err
+	}
+	return DecayHotRanks(db)
+}
+
+// re-scores every ranked post with the current time, drops posts that left
+// the window and trims each ranking to hotCandidatePool members;
+// a missing ranking is seeded from MySQL
+func DecayHotRanks(db *gorm.DB) error {
+	for w := range HotWindows {
+		if err := decayHotRank(db, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decayHotRank(db *gorm.DB, window string) error {
+	key := hotRankKey(window)
+
+	exists, err := config.Rdb.Exists(hotCtx, key).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return seedHotRank(db, window)
+	}
+
+	members, err := config.Rdb.ZRevRange(hotCtx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		id64, err := strconv.ParseUint(m, 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		ids = append(ids, uint(id64))
+	}
+
+	posts, err := GetPostsByIDs(db, ids)
+	if err != nil {
+		return err
+	}
+	likes := LiveLikeCounts(posts)
+
+	now := time.Now()
+	alive := make(map[uint]bool, len(posts))
+
+	pipe := config.Rdb.Pipeline()
+	for _, p := range posts {
+		if now.Sub(p.CreatedAt) > HotWindows[window] {
+			continue
+		}
+		alive[p.ID] = true
+		score := hotScorer.Score(newHotCandidate(p, likes[p.ID]), now)
+		pipe.ZAdd(hotCtx, key, redis.Z{Score: score, Member: p.ID})
+	}
+	for _, id := range ids {
+		if !alive[id] {
+			pipe.ZRem(hotCtx, key, id)
+		}
+	}
+	pipe.ZRemRangeByRank(hotCtx, key, 0, -hotCandidatePool-1)
+
+	_, err = pipe.Exec(hotCtx)
+	return err
+}
+
+// fills an empty ranking from the posts created within the window
+func seedHotRank(db *gorm.DB, window string) error {
+	now := time.Now()
+
+	var posts []model.Post
+	if err := db.Where("created_at >= ?", now.Add(-HotWindows[window])).
+		Order("like_count DESC").Limit(hotCandidatePool).Find(&posts).Error; err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return nil
+	}
+	likes := LiveLikeCounts(posts)
+
+	zs := make([]redis.Z, 0, len(posts))
+	for _, p := range posts {
+		zs = append(zs, redis.Z{
+			Score:  hotScorer.Score(newHotCandidate(p, likes[p.ID]), now),
+			Member: p.ID,
+		})
+	}
+
+	return config.Rdb.ZAdd(hotCtx, hotRankKey(window), zs...).Err()
+}
+
+// like counts from Redis, which run ahead of the MySQL column until the next sync
+func LiveLikeCounts(posts []model.Post) map[uint]int64 {
+	counts := make(map[uint]int64, len(posts))
+	if len(posts) == 0 {
+		return counts
+	}
+
+	keys := make([]string, len(posts))
+	for i, p := range posts {
+		keys[i] = fmt.Sprintf("like_count:%d", p.ID)
+		counts[p.ID] = int64(p.LikeCount)
+	}
+
+	vals, err := config.Rdb.MGet(hotCtx, keys...).Result()
+	if err != nil {
+		return counts
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			counts[posts[i].ID] = n
+		}
+	}
+	return counts
+}
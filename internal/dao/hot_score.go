@@ -0,0 +1,92 @@
+package dao
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"minifeed/internal/model"
+)
+
+// everything a scorer may look at when ranking a post
+type HotCandidate struct {
+	PostID    uint
+	AuthorID  uint
+	Likes     int
+	Comments  int // posts have no comments yet, so this stays 0 until they do
+	CreatedAt time.Time
+}
+
+// pluggable scoring function for the hot ranking
+type HotScorer interface {
+	Score(c HotCandidate, now time.Time) float64
+}
+
+// gravity-style score: engagement points divided by (age in hours + 2) ^ Gravity
+type GravityScorer struct {
+	LikeWeight    float64
+	CommentWeight float64
+	Gravity       float64
+}
+
+func (g GravityScorer) Score(c HotCandidate, now time.Time) float64 {
+	points := g.LikeWeight*float64(c.Likes) + g.CommentWeight*float64(c.Comments) + 1
+
+	ageHours := now.Sub(c.CreatedAt).Hours()
+	if ageHours < 0 {
+		ageHours = 0
+	}
+
+	return points / math.Pow(ageHours+2, g.Gravity)
+}
+
+var (
+	hotScorer HotScorer = GravityScorer{LikeWeight: 1, CommentWeight: 2, Gravity: 1.8}
+
+	// each extra post by the same author keeps only this fraction of its score
+	hotAuthorPenalty = 0.7
+)
+
+// swaps the scoring function, e.g. to experiment with weights
+func SetHotScorer(s HotScorer) {
+	if s != nil {
+		hotScorer = s
+	}
+}
+
+// sets the per-author decay used to keep one author from filling the list
+func SetHotAuthorPenalty(penalty float64) {
+	if penalty > 0 && penalty <= 1 {
+		hotAuthorPenalty = penalty
+	}
+}
+
+func newHotCandidate(p model.Post, likes int64) HotCandidate {
+	return HotCandidate{
+		PostID:    p.ID,
+		AuthorID:  p.UserID,
+		Likes:     int(likes),
+		CreatedAt: p.CreatedAt,
+	}
+}
+
+// re-ranks posts so the k-th post of an author scores penalty^k of its raw score
+func diversifyByAuthor(posts []model.Post, scores map[uint]float64) []model.Post {
+	sorted := make([]model.Post, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i].ID] > scores[sorted[j].ID]
+	})
+
+	seen := make(map[uint]int, len(sorted))
+	adjusted := make(map[uint]float64, len(sorted))
+	for _, p := range sorted {
+		adjusted[p.ID] = scores[p.ID] * math.Pow(hotAuthorPenalty, float64(seen[p.UserID]))
+		seen[p.UserID]++
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return adjusted[sorted[i].ID] > adjusted[sorted[j].ID]
+	})
+	return sorted
+}
@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"minifeed/internal/config"
+)
+
+// every replica subscribes to this channel and evicts its L1 entries on each message
+const cacheInvalidateChannel = "cache:invalidate"
+
+var invalidateCtx = context.Background()
+
+// evicts L1 keys locally and broadcasts the eviction to the other replicas
+func publishInvalidation(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	evictLocal(keys...)
+	_ = config.Rdb.Publish(invalidateCtx, cacheInvalidateChannel, strings.Join(keys, ",")).Err()
+}
+
+func evictLocal(keys ...string) {
+	for _, key := range keys {
+		switch {
+		case strings.HasPrefix(key, hotPostsPrefix):
+			hotL1.Del(key)
+		case strings.HasPrefix(key, postCachePrefix):
+			postL1.Del(key)
+		case strings.HasPrefix(key, userStatusPrefix):
+			userStatusL1.Del(key)
+		}
+	}
+}
+
+// listens for invalidation messages from other replicas
+func StartCacheInvalidation() {
+	sub := config.Rdb.Subscribe(invalidateCtx, cacheInvalidateChannel)
+	if _, err := sub.Receive(invalidateCtx); err != nil {
+		log.Printf("[warn] subscribe %s failed: %v\n", cacheInvalidateChannel, err)
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			evictLocal(strings.Split(msg.Payload, ",")...)
+		}
+	}()
+}
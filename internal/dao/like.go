@@ -2,6 +2,7 @@ package dao
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -55,16 +56,38 @@ func ExtractPostID(key string) (uint, error) {
 	return uint(id64), err
 }
 
-// updates MySQL like_count
-func UpdatePostLikeCount(db *gorm.DB, postID uint, count uint) error {
-	DelHotPostsCache()
+// updates MySQL like_count, reporting whether it changed; the post caches are
+// only invalidated then, so an unchanged count keeps the post hot in them
+func UpdatePostLikeCount(db *gorm.DB, postID uint, count uint) (bool, error) {
+	res := db.Model(&model.Post{}).Where("id = ? AND like_count <> ?", postID, count).Update("like_count", count)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
 
-	err := db.Model(&model.Post{}).Where("id = ?", postID).Update("like_count", count).Error
-	if err != nil {
+	DelPostCache(postID)
+	DelPostCacheAsync(postID)
+	return true, nil
+}
+
+// drops every like of a post: the liker set and counter in Redis, the MySQL
+// column and the post's hot score
+func ResetPostLikes(db *gorm.DB, p model.Post) error {
+	DelPostCache(p.ID)
+
+	pipe := config.Rdb.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("like:%d", p.ID))
+	pipe.Set(ctx, fmt.Sprintf("like_count:%d", p.ID), 0, 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if err := db.Model(&model.Post{}).Where("id = ?", p.ID).Update("like_count", 0).Error; err != nil {
 		return err
 	}
 
-	DelHotPostsCacheAsync()
-
+	DelPostCacheAsync(p.ID)
+	UpdateHotRankLikes(p, 0)
 	return nil
 }
@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"minifeed/internal/metrics"
+)
+
+// bounded in-process LRU with per-entry TTL, used as the L1 tier in front of Redis
+type localCache[V any] struct {
+	name  string
+	cap   int
+	ttl   time.Duration
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type localEntry[V any] struct {
+	key      string
+	val      V
+	expireAt time.Time
+}
+
+func newLocalCache[V any](name string, capacity int, ttl time.Duration) *localCache[V] {
+	return &localCache[V]{
+		name:  name,
+		cap:   capacity,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, capacity),
+	}
+}
+
+func (c *localCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		metrics.CacheRequestsTotal.WithLabelValues(c.name, "l1", "miss").Inc()
+		return zero, false
+	}
+
+	e := el.Value.(*localEntry[V])
+	if time.Now().After(e.expireAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		metrics.CacheRequestsTotal.WithLabelValues(c.name, "l1", "miss").Inc()
+		return zero, false
+	}
+
+	c.ll.MoveToFront(el)
+	metrics.CacheRequestsTotal.WithLabelValues(c.name, "l1", "hit").Inc()
+	return e.val, true
+}
+
+func (c *localCache[V]) Set(key string, val V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expireAt := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*localEntry[V])
+		e.val = val
+		e.expireAt = expireAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&localEntry[V]{key: key, val: val, expireAt: expireAt})
+
+	for c.ll.Len() > c.cap {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*localEntry[V]).key)
+	}
+}
+
+func (c *localCache[V]) Del(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+func (c *localCache[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element, c.cap)
+}
@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"minifeed/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	lockCtx = context.Background()
+
+	ErrLockNotHeld = errors.New("lock not held")
+)
+
+// deletes the key only if it still holds our token, so an expired lock taken
+// over by another replica is never released by mistake
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extends the TTL only while the key still holds our token
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// distributed lock owned by a random token, released automatically after its TTL
+type Lock struct {
+	key   string
+	token string
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// tries once to take the lock, reporting false when another holder has it
+func TryLock(key string, ttl time.Duration) (*Lock, bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := config.Rdb.SetNX(lockCtx, key, token, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	return &Lock{key: key, token: token}, true, nil
+}
+
+// releases the lock if it is still ours
+func (l *Lock) Unlock() error {
+	n, err := unlockScript.Run(lockCtx, config.Rdb, []string{l.key}, l.token).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// extends the lock for long-running holders
+func (l *Lock) Refresh(ttl time.Duration) error {
+	n, err := refreshScript.Run(lockCtx, config.Rdb, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// runs fn only if the lock can be taken, for jobs that must not run on every replica at once
+func WithLock(key string, ttl time.Duration, fn func() error) (bool, error) {
+	l, ok, err := TryLock(key, ttl)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer l.Unlock()
+
+	return true, fn()
+}
+
+// adapts the Redis lock to cache.Locker
+type redisLocker struct{}
+
+func (redisLocker) TryLock(key string, ttl time.Duration) (func(), bool, error) {
+	l, ok, err := TryLock(key, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func() { _ = l.Unlock() }, true, nil
+}
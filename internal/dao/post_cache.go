@@ -0,0 +1,246 @@
+package dao
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/metrics"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	postCachePrefix = "post:obj:"
+	postCacheTTL    = 10 * time.Minute
+	postNullTTL     = 30 * time.Second
+	postNullValue   = "null"
+)
+
+var (
+	postCtx   = context.Background()
+	postLoads = &postFlight{calls: make(map[uint]*postCall)}
+	postL1    = newLocalCache[model.Post]("post", 10000, 30*time.Second)
+)
+
+func postCacheKey(postID uint) string {
+	return fmt.Sprintf("%s%d", postCachePrefix, postID)
+}
+
+// one in-flight load of a single post, shared by every caller asking for it
+type postCall struct {
+	done chan struct{}
+	post *model.Post
+	err  error
+}
+
+// per-key singleflight: ids already being loaded are waited on,
+// the rest are loaded together in one query
+type postFlight struct {
+	mu    sync.Mutex
+	calls map[uint]*postCall
+}
+
+func (g *postFlight) load(db *gorm.DB, ids []uint) (map[uint]*model.Post, error) {
+	owned := make(map[uint]*postCall)
+	waiting := make(map[uint]*postCall)
+
+	g.mu.Lock()
+	for _, id := range ids {
+		if c, ok := g.calls[id]; ok {
+			waiting[id] = c
+			continue
+		}
+		c := &postCall{done: make(chan struct{})}
+		g.calls[id] = c
+		owned[id] = c
+	}
+	g.mu.Unlock()
+
+	if len(owned) > 0 {
+		ownedIDs := make([]uint, 0, len(owned))
+		for id := range owned {
+			ownedIDs = append(ownedIDs, id)
+		}
+
+		found, err := loadPostsFromDB(db, ownedIDs)
+
+		g.mu.Lock()
+		for id, c := range owned {
+			c.err = err
+			c.post = found[id]
+			delete(g.calls, id)
+			close(c.done)
+		}
+		g.mu.Unlock()
+	}
+
+	result := make(map[uint]*model.Post, len(ids))
+	for id, c := range owned {
+		if c.err != nil {
+			return nil, c.err
+		}
+		if c.post != nil {
+			result[id] = c.post
+		}
+	}
+	for id, c := range waiting {
+		<-c.done
+		if c.err != nil {
+			return nil, c.err
+		}
+		if c.post != nil {
+			result[id] = c.post
+		}
+	}
+
+	return result, nil
+}
+
+// queries MySQL and back-fills Redis, caching a null marker for missing ids
+func loadPostsFromDB(db *gorm.DB, ids []uint) (map[uint]*model.Post, error) {
+	var posts []model.Post
+	if err := db.Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]*model.Post, len(posts))
+	for i := range posts {
+		found[posts[i].ID] = &posts[i]
+	}
+
+	pipe := config.Rdb.Pipeline()
+	for _, id := range ids {
+		p, ok := found[id]
+		if !ok {
+			pipe.Set(postCtx, postCacheKey(id), postNullValue, postNullTTL)
+			continue
+		}
+
+		data, err := json.Marshal(p)
+		if err != nil {
+			continue
+		}
+		jitter := time.Duration(rand.Intn(60)) * time.Second
+		pipe.Set(postCtx, postCacheKey(id), data, postCacheTTL+jitter)
+	}
+	_, _ = pipe.Exec(postCtx)
+
+	return found, nil
+}
+
+// resolves post ids through the cache, keeping the order of ids and skipping missing posts
+func GetPostsByIDs(db *gorm.DB, ids []uint) ([]model.Post, error) {
+	if len(ids) == 0 {
+		return []model.Post{}, nil
+	}
+
+	m := make(map[uint]*model.Post, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	remote := make([]uint, 0, len(ids))
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if p, ok := postL1.Get(postCacheKey(id)); ok {
+			m[id] = &p
+			continue
+		}
+		remote = append(remote, id)
+	}
+
+	missing := make([]uint, 0)
+	if len(remote) > 0 {
+		keys := make([]string, len(remote))
+		for i, id := range remote {
+			keys[i] = postCacheKey(id)
+		}
+
+		vals, err := config.Rdb.MGet(postCtx, keys...).Result()
+		if err != nil {
+			vals = make([]interface{}, len(remote))
+		}
+
+		for i, id := range remote {
+			s, ok := vals[i].(string)
+			if !ok {
+				metrics.CacheRequestsTotal.WithLabelValues("post", "redis", "miss").Inc()
+				missing = append(missing, id)
+				continue
+			}
+			metrics.CacheRequestsTotal.WithLabelValues("post", "redis", "hit").Inc()
+			if s == postNullValue {
+				continue
+			}
+
+			var p model.Post
+			if err := json.Unmarshal([]byte(s), &p); err != nil {
+				missing = append(missing, id)
+				continue
+			}
+			m[id] = &p
+			postL1.Set(keys[i], p)
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := postLoads.load(db, missing)
+		if err != nil {
+			return nil, err
+		}
+		for id, p := range loaded {
+			m[id] = p
+			postL1.Set(postCacheKey(id), *p)
+		}
+	}
+
+	ordered := make([]model.Post, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := m[id]; ok {
+			ordered = append(ordered, *p)
+		}
+	}
+
+	return ordered, nil
+}
+
+// reads a single post through the cache
+func GetPostByID(db *gorm.DB, postID uint) (*model.Post, error) {
+	posts, err := GetPostsByIDs(db, []uint{postID})
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &posts[0], nil
+}
+
+// delete before write
+func DelPostCache(postIDs ...uint) {
+	if len(postIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(postIDs))
+	for i, id := range postIDs {
+		keys[i] = postCacheKey(id)
+	}
+	_ = config.Rdb.Del(postCtx, keys...).Err()
+	publishInvalidation(keys...)
+}
+
+// delete after write
+func DelPostCacheAsync(postIDs ...uint) {
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		DelPostCache(postIDs...)
+	}()
+}
@@ -0,0 +1,94 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"minifeed/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ranked:{uid}:{snapshot} is a list of post ids in ranked order; paging reads
+// slices of it so a ranking does not shift under a reader between pages
+const (
+	rankedSnapshotPrefix = "ranked:"
+	rankedSnapshotTTL    = 30 * time.Minute
+)
+
+// impressions:{uid} is set while a reader's latest ranking is the one logged
+// for offline evaluation, so refreshing the feed does not log every snapshot
+const (
+	impressionLogPrefix = "impressions:"
+	impressionLogWindow = 10 * time.Minute
+)
+
+var (
+	ErrSnapshotExpired = errors.New("ranked feed snapshot expired")
+
+	snapshotCtx = context.Background()
+)
+
+func rankedSnapshotKey(userID uint, snapshotID string) string {
+	return fmt.Sprintf("%s%d:%s", rankedSnapshotPrefix, userID, snapshotID)
+}
+
+// stores a ranking and returns its id
+func SaveRankedSnapshot(userID uint, ids []uint) (string, error) {
+	snapshotID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if len(ids) == 0 {
+		return snapshotID, nil
+	}
+
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+
+	key := rankedSnapshotKey(userID, snapshotID)
+	pipe := config.Rdb.TxPipeline()
+	pipe.RPush(snapshotCtx, key, members...)
+	pipe.Expire(snapshotCtx, key, rankedSnapshotTTL)
+	if _, err := pipe.Exec(snapshotCtx); err != nil {
+		return "", err
+	}
+	return snapshotID, nil
+}
+
+// ids at [offset, offset+limit) of a snapshot and whether more follow
+func GetRankedSnapshotPage(userID uint, snapshotID string, offset, limit int) ([]uint, bool, error) {
+	key := rankedSnapshotKey(userID, snapshotID)
+
+	pipe := config.Rdb.Pipeline()
+	rangeCmd := pipe.LRange(snapshotCtx, key, int64(offset), int64(offset+limit-1))
+	lenCmd := pipe.LLen(snapshotCtx, key)
+	if _, err := pipe.Exec(snapshotCtx); err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+
+	total := lenCmd.Val()
+	if total == 0 {
+		return nil, false, ErrSnapshotExpired
+	}
+
+	vals := rangeCmd.Val()
+	ids := make([]uint, 0, len(vals))
+	for _, v := range vals {
+		id64, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		ids = append(ids, uint(id64))
+	}
+	return ids, int64(offset+len(vals)) < total, nil
+}
+
+// whether this snapshot of userID's feed should be logged: true at most once
+// per impressionLogWindow
+func ClaimImpressionLog(userID uint) (bool, error) {
+	key := fmt.Sprintf("%s%d", impressionLogPrefix, userID)
+	return config.Rdb.SetNX(snapshotCtx, key, 1, impressionLogWindow).Result()
+}
@@ -0,0 +1,226 @@
+package dao
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"minifeed/internal/config"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// cached sets of a directed user relation (follow, block, mute), both sides:
+//
+//	{name}:out:{uid}   ids uid points at
+//	{name}:in:{uid}    ids pointing at uid
+//
+// each set also holds the sentinel member "0" so an empty set is
+// distinguishable from one that is not cached
+type relationSets struct {
+	name    string
+	table   string
+	fromCol string
+	toCol   string
+}
+
+var (
+	followSets = relationSets{name: "follow", table: "follows", fromCol: "user_id", toCol: "follow_id"}
+	blockSets  = relationSets{name: "block", table: "blocks", fromCol: "user_id", toCol: "target_id"}
+	muteSets   = relationSets{name: "mute", table: "mutes", fromCol: "user_id", toCol: "target_id"}
+)
+
+const (
+	relationSetSentinel = "0"
+	relationSetTTL      = 30 * time.Minute
+)
+
+var relationCtx = context.Background()
+
+// only touches sets that are cached; a missing set is loaded from MySQL on next read
+var relationSetUpdateScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call(ARGV[1], KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (r relationSets) outKey(userID uint) string {
+	return r.name + ":out:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func (r relationSets) inKey(userID uint) string {
+	return r.name + ":in:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func relationSetExpiry() time.Duration {
+	return relationSetTTL + time.Duration(rand.Intn(300))*time.Second
+}
+
+// records userID -> targetID in both cached sets
+func (r relationSets) add(userID, targetID uint) {
+	r.update("SADD", userID, targetID)
+}
+
+// drops userID -> targetID from both cached sets
+func (r relationSets) remove(userID, targetID uint) {
+	r.update("SREM", userID, targetID)
+}
+
+// called after the write committed; the delayed delete drops a set that a
+// concurrent load filled from a snapshot taken before the write
+func (r relationSets) update(op string, userID, targetID uint) {
+	keys := []string{r.outKey(userID), r.inKey(targetID)}
+
+	pipe := config.Rdb.Pipeline()
+	relationSetUpdateScript.Eval(relationCtx, pipe, keys[:1], op, targetID)
+	relationSetUpdateScript.Eval(relationCtx, pipe, keys[1:], op, userID)
+	if _, err := pipe.Exec(relationCtx); err != nil {
+		// a stale set would keep answering wrongly until it expires
+		config.Rdb.Del(relationCtx, keys...)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		config.Rdb.Del(relationCtx, keys...)
+	}()
+}
+
+// one side of userID's relation to test targets against
+type setQuery struct {
+	key      string
+	table    string
+	ownCol   string
+	otherCol string
+	userID   uint
+}
+
+// targets userID points at
+func (r relationSets) out(userID uint) setQuery {
+	return setQuery{key: r.outKey(userID), table: r.table, ownCol: r.fromCol, otherCol: r.toCol, userID: userID}
+}
+
+// targets pointing at userID
+func (r relationSets) in(userID uint) setQuery {
+	return setQuery{key: r.inKey(userID), table: r.table, ownCol: r.toCol, otherCol: r.fromCol, userID: userID}
+}
+
+// tests every target against every query; a warm cache answers in a single round trip
+func testRelations(db *gorm.DB, targetIDs []uint, queries ...setQuery) ([][]bool, error) {
+	members := make([]interface{}, 0, len(targetIDs)+1)
+	members = append(members, relationSetSentinel)
+	for _, id := range targetIDs {
+		members = append(members, id)
+	}
+
+	pipe := config.Rdb.Pipeline()
+	cmds := make([]*redis.BoolSliceCmd, len(queries))
+	for i, q := range queries {
+		cmds[i] = pipe.SMIsMember(relationCtx, q.key, members...)
+	}
+	if _, err := pipe.Exec(relationCtx); err != nil {
+		return nil, err
+	}
+
+	results := make([][]bool, len(queries))
+	for i, q := range queries {
+		flags, err := q.flags(db, cmds[i].Val(), targetIDs)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = flags
+	}
+	return results, nil
+}
+
+// answers from the SMISMEMBER result when the set is cached, otherwise loads it
+func (q setQuery) flags(db *gorm.DB, hits []bool, targetIDs []uint) ([]bool, error) {
+	if len(hits) > 0 && hits[0] {
+		return hits[1:], nil
+	}
+
+	set, err := q.load(db)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make([]bool, len(targetIDs))
+	for i, id := range targetIDs {
+		_, flags[i] = set[id]
+	}
+	return flags, nil
+}
+
+// reads one side of the relation from MySQL and caches it
+func (q setQuery) load(db *gorm.DB) (map[uint]struct{}, error) {
+	var ids []uint
+	if err := db.Table(q.table).Where(q.ownCol+" = ?", q.userID).Pluck(q.otherCol, &ids).Error; err != nil {
+		return nil, err
+	}
+
+	set := make(map[uint]struct{}, len(ids))
+	members := make([]interface{}, 0, len(ids)+1)
+	members = append(members, relationSetSentinel)
+	for _, id := range ids {
+		set[id] = struct{}{}
+		members = append(members, id)
+	}
+
+	pipe := config.Rdb.TxPipeline()
+	pipe.Del(relationCtx, q.key)
+	pipe.SAdd(relationCtx, q.key, members...)
+	pipe.Expire(relationCtx, q.key, relationSetExpiry())
+	_, _ = pipe.Exec(relationCtx)
+
+	return set, nil
+}
+
+func AddFollowToCache(userID, targetID uint)      { followSets.add(userID, targetID) }
+func RemoveFollowFromCache(userID, targetID uint) { followSets.remove(userID, targetID) }
+func AddBlockToCache(userID, targetID uint)       { blockSets.add(userID, targetID) }
+func RemoveBlockFromCache(userID, targetID uint)  { blockSets.remove(userID, targetID) }
+func AddMuteToCache(userID, targetID uint)        { muteSets.add(userID, targetID) }
+func RemoveMuteFromCache(userID, targetID uint)   { muteSets.remove(userID, targetID) }
+
+// every relation of userID with each target, index-aligned with targetIDs
+type RelationFlags struct {
+	Following  []bool // userID follows the target
+	FollowedBy []bool // the target follows userID
+	Blocking   []bool // userID blocked the target
+	BlockedBy  []bool // the target blocked userID
+	Muting     []bool // userID muted the target
+}
+
+func GetRelationFlags(db *gorm.DB, userID uint, targetIDs []uint) (*RelationFlags, error) {
+	res, err := testRelations(db, targetIDs,
+		followSets.out(userID), followSets.in(userID),
+		blockSets.out(userID), blockSets.in(userID),
+		muteSets.out(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelationFlags{
+		Following:  res[0],
+		FollowedBy: res[1],
+		Blocking:   res[2],
+		BlockedBy:  res[3],
+		Muting:     res[4],
+	}, nil
+}
+
+// reports, per target, whether a block exists between userID and it in either direction
+func BlockedEitherWay(db *gorm.DB, userID uint, targetIDs []uint) ([]bool, error) {
+	res, err := testRelations(db, targetIDs, blockSets.out(userID), blockSets.in(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	blocked := make([]bool, len(targetIDs))
+	for i := range targetIDs {
+		blocked[i] = res[0][i] || res[1][i]
+	}
+	return blocked, nil
+}
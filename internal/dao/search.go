@@ -0,0 +1,224 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchBackendMySQL = "mysql"
+	SearchBackendBleve = "bleve"
+
+	SearchSortRelevance = "relevance"
+	SearchSortRecent    = "recent"
+)
+
+var (
+	ErrEmptySearch         = errors.New("empty search")
+	ErrInvalidSearchCursor = errors.New("invalid search cursor")
+)
+
+// a parsed post search: every term, phrase and prefix must match, and the
+// filters narrow the matches down
+type PostQuery struct {
+	Terms    []string
+	Phrases  []string
+	Prefixes []string
+
+	AuthorID uint
+	Hashtag  string    // lowercase, without '#'
+	Since    time.Time // inclusive, zero for no bound
+	Until    time.Time // exclusive, zero for no bound
+
+	Sort string // SearchSortRelevance or SearchSortRecent
+}
+
+func (q PostQuery) hasText() bool {
+	return len(q.Terms)+len(q.Phrases)+len(q.Prefixes) > 0
+}
+
+// full-text index of posts; a cursor is "<score>_<post id>" of the last hit of a page
+// (score 0 when sorted by recency) and is opaque to callers
+type PostIndex interface {
+	Index(p model.Post) error
+	Delete(postID uint) error
+	// ids of the matching posts, best first, and the cursor of the next page ("" at the end)
+	Search(q PostQuery, cursor string, limit int) ([]uint, string, error)
+}
+
+// parses the q parameter: "quoted phrases", prefix* words, #hashtags and plain words.
+// a #hashtag becomes the hashtag filter when none is given explicitly
+func ParsePostQuery(raw string) PostQuery {
+	var q PostQuery
+
+	rest := raw
+	for {
+		start := strings.IndexByte(rest, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start+1:], '"')
+		if end < 0 {
+			break
+		}
+		if words := searchWords(rest[start+1 : start+1+end]); len(words) > 0 {
+			q.Phrases = append(q.Phrases, strings.Join(words, " "))
+		}
+		rest = rest[:start] + " " + rest[start+1+end+1:]
+	}
+
+	for _, field := range strings.Fields(rest) {
+		switch {
+		case strings.HasPrefix(field, "#"):
+			if tags := model.ExtractHashtags(field); len(tags) > 0 && q.Hashtag == "" {
+				q.Hashtag = tags[0]
+			}
+		case strings.HasSuffix(field, "*"):
+			if words := searchWords(field); len(words) == 1 {
+				q.Prefixes = append(q.Prefixes, words[0])
+			} else {
+				q.Terms = append(q.Terms, words...)
+			}
+		default:
+			q.Terms = append(q.Terms, searchWords(field)...)
+		}
+	}
+	return q
+}
+
+// lowercase words of s with every operator and punctuation character dropped
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+}
+
+var (
+	searchMu      sync.RWMutex
+	searchBackend = SearchBackendMySQL
+	postIndex     PostIndex
+)
+
+// opens the post index; backend "bleve" keeps an embedded index at path (in memory
+// when path is empty), anything else uses the FULLTEXT index on posts.content
+func InitSearch(db *gorm.DB, backend, path string) error {
+	var idx PostIndex
+	if backend == SearchBackendBleve {
+		b, err := openBleveIndex(path)
+		if err != nil {
+			return err
+		}
+		idx = b
+	} else {
+		backend = SearchBackendMySQL
+		idx = &mysqlIndex{db: db}
+	}
+
+	searchMu.Lock()
+	searchBackend = backend
+	postIndex = idx
+	searchMu.Unlock()
+	return nil
+}
+
+// indexes posts the embedded index has not seen yet, returning how many;
+// the FULLTEXT index is maintained by MySQL and needs nothing
+func CatchUpSearchIndex(db *gorm.DB) (int, error) {
+	b, ok := currentPostIndex().(*bleveIndex)
+	if !ok {
+		return 0, nil
+	}
+	return b.catchUp(db)
+}
+
+func currentPostIndex() PostIndex {
+	searchMu.RLock()
+	defer searchMu.RUnlock()
+	return postIndex
+}
+
+// records a new post's hashtags and adds it to the search index
+func IndexPost(db *gorm.DB, p model.Post) {
+	if tags := model.ExtractHashtags(p.Content); len(tags) > 0 {
+		rows := make([]model.PostHashtag, len(tags))
+		for i, tag := range tags {
+			rows[i] = model.PostHashtag{Tag: tag, PostID: p.ID}
+		}
+		if err := db.Create(&rows).Error; err != nil {
+			log.Printf("[warn] save hashtags of post %d failed: %v\n", p.ID, err)
+		}
+	}
+
+	idx := currentPostIndex()
+	if idx == nil {
+		return
+	}
+	if err := idx.Index(p); err != nil {
+		log.Printf("[warn] index post %d failed: %v\n", p.ID, err)
+	}
+}
+
+// takes a deleted post out of the search index
+func RemovePostFromIndex(db *gorm.DB, postID uint) {
+	if err := db.Where("post_id = ?", postID).Delete(&model.PostHashtag{}).Error; err != nil {
+		log.Printf("[warn] delete hashtags of post %d failed: %v\n", postID, err)
+	}
+
+	idx := currentPostIndex()
+	if idx == nil {
+		return
+	}
+	if err := idx.Delete(postID); err != nil {
+		log.Printf("[warn] unindex post %d failed: %v\n", postID, err)
+	}
+}
+
+// one page of post ids matching q
+func SearchPosts(db *gorm.DB, q PostQuery, cursor string, limit int) ([]uint, string, error) {
+	if !q.hasText() && q.AuthorID == 0 && q.Hashtag == "" {
+		return nil, "", ErrEmptySearch
+	}
+	if q.Sort != SearchSortRecent {
+		q.Sort = SearchSortRelevance
+	}
+
+	idx := currentPostIndex()
+	if idx == nil {
+		idx = &mysqlIndex{db: db}
+	}
+	ids, next, err := idx.Search(q, cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("search %s: %w", searchBackendName(), err)
+	}
+	return ids, next, nil
+}
+
+func searchBackendName() string {
+	searchMu.RLock()
+	defer searchMu.RUnlock()
+	return searchBackend
+}
+
+func searchCursor(score float64, postID uint) string {
+	return fmt.Sprintf("%s_%d", strconv.FormatFloat(score, 'g', -1, 64), postID)
+}
+
+func parseSearchCursor(cursor string) (float64, uint64, error) {
+	scoreStr, idStr, ok := strings.Cut(cursor, "_")
+	score, err1 := strconv.ParseFloat(scoreStr, 64)
+	id, err2 := strconv.ParseUint(idStr, 10, 64)
+	if !ok || err1 != nil || err2 != nil || id == 0 {
+		return 0, 0, ErrInvalidSearchCursor
+	}
+	return score, id, nil
+}
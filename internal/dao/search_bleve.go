@@ -0,0 +1,212 @@
+package dao
+
+import (
+	"os"
+	"strconv"
+
+	"minifeed/internal/model"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"gorm.io/gorm"
+)
+
+// embedded inverted index (bleve), on disk or in memory, so search runs without
+// an external service. Each replica holds its own index: the id of the newest post
+// indexed by a catch-up is kept inside the index, and the periodic catch-up picks
+// up posts written since, including those created through other replicas.
+type bleveIndex struct {
+	idx bleve.Index
+}
+
+const (
+	bleveCatchUpBatch  = 1000
+	bleveLastPostIDKey = "last_post_id"
+)
+
+func postIndexMapping() *mapping.IndexMappingImpl {
+	content := bleve.NewTextFieldMapping()
+	content.Analyzer = "standard"
+	content.Store = false
+
+	keyword := bleve.NewKeywordFieldMapping()
+	keyword.Store = false
+
+	numeric := bleve.NewNumericFieldMapping()
+	numeric.Store = false
+
+	date := bleve.NewDateTimeFieldMapping()
+	date.Store = false
+
+	doc := bleve.NewDocumentMapping()
+	doc.Dynamic = false
+	doc.AddFieldMappingsAt("content", content)
+	doc.AddFieldMappingsAt("author", keyword)
+	doc.AddFieldMappingsAt("hashtags", keyword)
+	doc.AddFieldMappingsAt("pid", numeric)
+	doc.AddFieldMappingsAt("created_at", date)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.StoreDynamic = false
+	m.IndexDynamic = false
+	return m
+}
+
+func openBleveIndex(path string) (*bleveIndex, error) {
+	m := postIndexMapping()
+	if path == "" {
+		idx, err := bleve.NewMemOnly(m)
+		if err != nil {
+			return nil, err
+		}
+		return &bleveIndex{idx: idx}, nil
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		idx, err := bleve.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return &bleveIndex{idx: idx}, nil
+	}
+	idx, err := bleve.New(path, m)
+	if err != nil {
+		return nil, err
+	}
+	return &bleveIndex{idx: idx}, nil
+}
+
+func bleveDocID(postID uint) string {
+	return strconv.FormatUint(uint64(postID), 10)
+}
+
+func bleveDoc(p model.Post) map[string]interface{} {
+	return map[string]interface{}{
+		"content":    p.Content,
+		"author":     strconv.FormatUint(uint64(p.UserID), 10),
+		"hashtags":   model.ExtractHashtags(p.Content),
+		"pid":        float64(p.ID),
+		"created_at": p.CreatedAt,
+	}
+}
+
+func (b *bleveIndex) Index(p model.Post) error {
+	return b.idx.Index(bleveDocID(p.ID), bleveDoc(p))
+}
+
+func (b *bleveIndex) Delete(postID uint) error {
+	return b.idx.Delete(bleveDocID(postID))
+}
+
+// indexes the posts written since the last indexed one, returning how many were added
+func (b *bleveIndex) catchUp(db *gorm.DB) (int, error) {
+	var lastID uint64
+	if raw, err := b.idx.GetInternal([]byte(bleveLastPostIDKey)); err == nil && len(raw) > 0 {
+		lastID, _ = strconv.ParseUint(string(raw), 10, 64)
+	}
+
+	total := 0
+	for {
+		var posts []model.Post
+		if err := db.Where("id > ?", lastID).Order("id").Limit(bleveCatchUpBatch).Find(&posts).Error; err != nil {
+			return total, err
+		}
+		if len(posts) == 0 {
+			return total, nil
+		}
+
+		batch := b.idx.NewBatch()
+		for _, p := range posts {
+			if err := batch.Index(bleveDocID(p.ID), bleveDoc(p)); err != nil {
+				return total, err
+			}
+		}
+		lastID = uint64(posts[len(posts)-1].ID)
+		batch.SetInternal([]byte(bleveLastPostIDKey), []byte(strconv.FormatUint(lastID, 10)))
+		if err := b.idx.Batch(batch); err != nil {
+			return total, err
+		}
+		total += len(posts)
+	}
+}
+
+func (b *bleveIndex) Search(q PostQuery, cursor string, limit int) ([]uint, string, error) {
+	var conjuncts []query.Query
+	for _, t := range q.Terms {
+		m := bleve.NewMatchQuery(t)
+		m.SetField("content")
+		conjuncts = append(conjuncts, m)
+	}
+	for _, p := range q.Prefixes {
+		pq := bleve.NewPrefixQuery(p)
+		pq.SetField("content")
+		conjuncts = append(conjuncts, pq)
+	}
+	for _, p := range q.Phrases {
+		pq := bleve.NewMatchPhraseQuery(p)
+		pq.SetField("content")
+		conjuncts = append(conjuncts, pq)
+	}
+	if q.AuthorID > 0 {
+		tq := bleve.NewTermQuery(strconv.FormatUint(uint64(q.AuthorID), 10))
+		tq.SetField("author")
+		conjuncts = append(conjuncts, tq)
+	}
+	if q.Hashtag != "" {
+		tq := bleve.NewTermQuery(q.Hashtag)
+		tq.SetField("hashtags")
+		conjuncts = append(conjuncts, tq)
+	}
+	if !q.Since.IsZero() || !q.Until.IsZero() {
+		inclusive, exclusive := true, false
+		dq := bleve.NewDateRangeInclusiveQuery(q.Since, q.Until, &inclusive, &exclusive)
+		dq.SetField("created_at")
+		conjuncts = append(conjuncts, dq)
+	}
+
+	relevance := q.Sort == SearchSortRelevance && q.hasText()
+	byID := &search.SortField{Field: "pid", Type: search.SortFieldAsNumber, Desc: true}
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), limit, 0, false)
+	if relevance {
+		req.SortByCustom(search.SortOrder{&search.SortScore{Desc: true}, byID})
+	} else {
+		req.SortByCustom(search.SortOrder{byID})
+	}
+	if cursor != "" {
+		score, id, err := parseSearchCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after := []string{strconv.FormatUint(id, 10)}
+		if relevance {
+			after = []string{strconv.FormatFloat(score, 'g', -1, 64), after[0]}
+		}
+		req.SetSearchAfter(after)
+	}
+
+	res, err := b.idx.Search(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids := make([]uint, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		id64, err := strconv.ParseUint(h.ID, 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		ids = append(ids, uint(id64))
+	}
+	next := ""
+	if len(res.Hits) == limit && len(ids) > 0 {
+		score := 0.0
+		if relevance {
+			score = res.Hits[len(res.Hits)-1].Score
+		}
+		next = searchCursor(score, ids[len(ids)-1])
+	}
+	return ids, next, nil
+}
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"strings"
+
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// search over the FULLTEXT index on posts.content in boolean mode; the table is
+// the index, so Index and Delete have nothing to do. Words shorter than
+// innodb_ft_min_token_size and stopwords are not indexed and never match
+type mysqlIndex struct {
+	db *gorm.DB
+}
+
+func (m *mysqlIndex) Index(model.Post) error { return nil }
+func (m *mysqlIndex) Delete(uint) error      { return nil }
+
+// +word +prefix* +"a phrase": every part is required
+func booleanQuery(q PostQuery) string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Prefixes)+len(q.Phrases))
+	for _, t := range q.Terms {
+		parts = append(parts, "+"+t)
+	}
+	for _, p := range q.Prefixes {
+		parts = append(parts, "+"+p+"*")
+	}
+	for _, p := range q.Phrases {
+		parts = append(parts, `+"`+p+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m *mysqlIndex) Search(q PostQuery, cursor string, limit int) ([]uint, string, error) {
+	var afterScore float64
+	var afterID uint64
+	if cursor != "" {
+		var err error
+		if afterScore, afterID, err = parseSearchCursor(cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := m.db.Model(&model.Post{}).Limit(limit)
+	if q.AuthorID > 0 {
+		query = query.Where("user_id = ?", q.AuthorID)
+	}
+	if q.Hashtag != "" {
+		query = query.Where("id IN (?)", m.db.Model(&model.PostHashtag{}).Select("post_id").Where("tag = ?", q.Hashtag))
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		query = query.Where("created_at < ?", q.Until)
+	}
+
+	relevance := q.Sort == SearchSortRelevance && q.hasText()
+	if q.hasText() {
+		expr := booleanQuery(q)
+		match := "MATCH(content) AGAINST(? IN BOOLEAN MODE)"
+		query = query.Where(match, expr)
+		if relevance {
+			query = query.Select("id, "+match+" AS score", expr).Order("score DESC, id DESC")
+			if cursor != "" {
+				query = query.Where("("+match+" < ? OR ("+match+" = ? AND id < ?))", expr, afterScore, expr, afterScore, afterID)
+			}
+		}
+	}
+	if !relevance {
+		query = query.Select("id, 0 AS score").Order("id DESC")
+		if cursor != "" {
+			query = query.Where("id < ?", afterID)
+		}
+	}
+
+	var hits []struct {
+		ID    uint
+		Score float64
+	}
+	if err := query.Scan(&hits).Error; err != nil {
+		return nil, "", err
+	}
+
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	next := ""
+	if len(hits) == limit {
+		last := hits[len(hits)-1]
+		next = searchCursor(last.Score, last.ID)
+	}
+	return ids, next, nil
+}
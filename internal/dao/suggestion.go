@@ -0,0 +1,236 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// suggest:{uid} is a ZSet of candidate id -> how many of uid's followings follow
+// the candidate, precomputed by the cron job; the sentinel "0" (score 0) marks a
+// computed list without candidates.
+// suggest:popular is a ZSet of user id -> follower count, the cold-start fallback.
+const (
+	suggestPrefix      = "suggest:"
+	suggestPopularKey  = "suggest:popular"
+	suggestSentinel    = "0"
+	suggestTTL         = 3 * time.Hour
+	suggestPopularTTL  = 2 * time.Hour
+	suggestCandidates  = 50
+	suggestPopularSize = 200
+	suggestBatchSize   = 500
+)
+
+var suggestCtx = context.Background()
+
+// a suggested account and the signal behind it: followings in common, or followers for popular ones
+type SuggestedUser struct {
+	UserID uint
+	Score  int64
+}
+
+func suggestKey(userID uint) string {
+	return fmt.Sprintf("%s%d", suggestPrefix, userID)
+}
+
+// accounts followed by the people userID follows, by how many of them follow each;
+// userID's own followings, pending requests, blocks either way and mutes are left out
+func friendsOfFriends(db *gorm.DB, userID uint, limit int) ([]SuggestedUser, error) {
+	var rows []struct {
+		UserID  uint
+		Mutuals int64
+	}
+	err := db.Raw(`
+SELECT f2.follow_id AS user_id, COUNT(*) AS mutuals
+FROM follows f1
+JOIN follows f2 ON f2.user_id = f1.follow_id
+WHERE f1.user_id = ?
+  AND f2.follow_id <> ?
+  AND f2.follow_id NOT IN (SELECT follow_id FROM follows WHERE user_id = ?)
+  AND f2.follow_id NOT IN (SELECT target_id FROM follow_requests WHERE user_id = ?)
+  AND f2.follow_id NOT IN (SELECT target_id FROM blocks WHERE user_id = ?)
+  AND f2.follow_id NOT IN (SELECT user_id FROM blocks WHERE target_id = ?)
+  AND f2.follow_id NOT IN (SELECT target_id FROM mutes WHERE user_id = ?)
+GROUP BY f2.follow_id
+ORDER BY mutuals DESC, f2.follow_id
+LIMIT ?`, userID, userID, userID, userID, userID, userID, userID, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]SuggestedUser, len(rows))
+	for i, r := range rows {
+		list[i] = SuggestedUser{UserID: r.UserID, Score: r.Mutuals}
+	}
+	return list, nil
+}
+
+// recomputes userID's friends-of-friends suggestions and stores them
+func ComputeSuggestions(db *gorm.DB, userID uint) ([]SuggestedUser, error) {
+	list, err := friendsOfFriends(db, userID, suggestCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]redis.Z, 0, len(list)+1)
+	members = append(members, redis.Z{Score: 0, Member: suggestSentinel})
+	for _, s := range list {
+		members = append(members, redis.Z{Score: float64(s.Score), Member: s.UserID})
+	}
+
+	key := suggestKey(userID)
+	pipe := config.Rdb.TxPipeline()
+	pipe.Del(suggestCtx, key)
+	pipe.ZAdd(suggestCtx, key, members...)
+	pipe.Expire(suggestCtx, key, suggestTTL)
+	if _, err := pipe.Exec(suggestCtx); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// userID's precomputed suggestions, computed on the spot when the job has not covered them yet
+func GetSuggestions(db *gorm.DB, userID uint) ([]SuggestedUser, error) {
+	zs, err := config.Rdb.ZRevRangeWithScores(suggestCtx, suggestKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(zs) == 0 {
+		return ComputeSuggestions(db, userID)
+	}
+	return parseSuggested(zs), nil
+}
+
+// drops userID's suggestions so the next read sees their new followings
+func InvalidateSuggestions(userID uint) {
+	config.Rdb.Del(suggestCtx, suggestKey(userID))
+}
+
+// the most followed accounts, read from the cached ranking
+func PopularUsers(db *gorm.DB) ([]SuggestedUser, error) {
+	zs, err := config.Rdb.ZRevRangeWithScores(suggestCtx, suggestPopularKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(zs) > 0 {
+		return parseSuggested(zs), nil
+	}
+	return refreshPopularUsers(db)
+}
+
+func refreshPopularUsers(db *gorm.DB) ([]SuggestedUser, error) {
+	var users []model.User
+	if err := db.Select("id", "follower_count").
+		Where("follower_count > 0").
+		Order("follower_count DESC, id").
+		Limit(suggestPopularSize).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]SuggestedUser, len(users))
+	members := make([]redis.Z, 0, len(users)+1)
+	members = append(members, redis.Z{Score: 0, Member: suggestSentinel})
+	for i, u := range users {
+		list[i] = SuggestedUser{UserID: u.ID, Score: u.FollowerCount}
+		members = append(members, redis.Z{Score: float64(u.FollowerCount), Member: u.ID})
+	}
+
+	pipe := config.Rdb.TxPipeline()
+	pipe.Del(suggestCtx, suggestPopularKey)
+	pipe.ZAdd(suggestCtx, suggestPopularKey, members...)
+	pipe.Expire(suggestCtx, suggestPopularKey, suggestPopularTTL)
+	if _, err := pipe.Exec(suggestCtx); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// recomputes the popular ranking and the suggestions of recently active users
+// who follow someone, those whose push inbox has not expired; anyone else gets
+// theirs computed on their next read, and users following nobody are served the
+// popular ranking directly. afterBatch runs after every batch, e.g. to renew a lock
+func RefreshSuggestions(db *gorm.DB, afterBatch func() error) (int, error) {
+	if _, err := refreshPopularUsers(db); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	var lastID uint
+	for {
+		var ids []uint
+		if err := db.Model(&model.User{}).
+			Where("id > ? AND following_count > 0", lastID).
+			Order("id").
+			Limit(suggestBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return done, err
+		}
+		if len(ids) == 0 {
+			return done, nil
+		}
+		lastID = ids[len(ids)-1]
+
+		active, err := activeReaders(ids)
+		if err != nil {
+			return done, err
+		}
+		var errs []error
+		for _, id := range active {
+			if _, err := ComputeSuggestions(db, id); err != nil {
+				errs = append(errs, fmt.Errorf("user %d: %w", id, err))
+				continue
+			}
+			done++
+		}
+		if len(errs) > 0 && len(errs) == len(active) {
+			return done, errors.Join(errs...)
+		}
+
+		if afterBatch != nil {
+			if err := afterBatch(); err != nil {
+				return done, err
+			}
+		}
+	}
+}
+
+// the users among ids whose push inbox still exists
+func activeReaders(ids []uint) ([]uint, error) {
+	pipe := config.Rdb.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.Exists(suggestCtx, InboxKey(id))
+	}
+	if _, err := pipe.Exec(suggestCtx); err != nil {
+		return nil, err
+	}
+
+	active := make([]uint, 0, len(ids))
+	for i, id := range ids {
+		if cmds[i].Val() > 0 {
+			active = append(active, id)
+		}
+	}
+	return active, nil
+}
+
+func parseSuggested(zs []redis.Z) []SuggestedUser {
+	list := make([]SuggestedUser, 0, len(zs))
+	for _, z := range zs {
+		id64, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		list = append(list, SuggestedUser{UserID: uint(id64), Score: int64(z.Score)})
+	}
+	return list
+}
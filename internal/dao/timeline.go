@@ -0,0 +1,262 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// per-author timeline: timeline:{uid} is a ZSet of the author's newest post ids
+// scored by id, capped at timelineMaxLen. One marker at score 0 tells readers
+// whether older posts exist only in MySQL:
+//
+//	"complete"    every post of the author is in the ZSet
+//	"truncated"   older posts were trimmed away
+//
+// a missing key means the timeline has to be loaded from MySQL
+const (
+	timelinePrefix    = "timeline:"
+	timelineMaxLen    = 500
+	timelineTTL       = 24 * time.Hour
+	timelineComplete  = "complete"
+	timelineTruncated = "truncated"
+)
+
+var timelineCtx = context.Background()
+
+// ARGV: max length, post id; only touches timelines that are cached
+var timelineAddScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[2])
+local removed = redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -(tonumber(ARGV[1]) + 1))
+if removed > 0 and redis.call("ZSCORE", KEYS[1], "complete") then
+	redis.call("ZREM", KEYS[1], "complete")
+	redis.call("ZADD", KEYS[1], 0, "truncated")
+end
+return 1
+`)
+
+func timelineKey(authorID uint) string {
+	return timelinePrefix + strconv.FormatUint(uint64(authorID), 10)
+}
+
+// inbox:{uid} is a reader's push inbox, kept by the service package; it expires
+// after INBOX_TTL without reads, so it also marks recently active readers
+func InboxKey(userID uint) string {
+	return fmt.Sprintf("inbox:%d", userID)
+}
+
+// appends a new post to its author's cached timeline
+func AddPostToTimeline(p model.Post) {
+	_ = timelineAddScript.Run(timelineCtx, config.Rdb, []string{timelineKey(p.UserID)}, timelineMaxLen, p.ID).Err()
+}
+
+// drops a post from its author's cached timeline
+func RemovePostFromTimeline(p model.Post) {
+	_ = config.Rdb.ZRem(timelineCtx, timelineKey(p.UserID), p.ID).Err()
+}
+
+// the newest post ids (below before, 0 for no bound) of each author, newest
+// first and at most limit per author; index-aligned with authorIDs
+func AuthorTimelines(db *gorm.DB, authorIDs []uint, before uint64, limit int) ([][]uint, error) {
+	max := "+inf"
+	if before > 0 {
+		max = fmt.Sprintf("(%d", before)
+	}
+
+	// the marker sorts below every post, so it only shows up once the cached ids run out
+	pipe := config.Rdb.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, len(authorIDs))
+	for i, id := range authorIDs {
+		cmds[i] = pipe.ZRevRangeByScoreWithScores(timelineCtx, timelineKey(id), &redis.ZRangeBy{
+			Max:   max,
+			Min:   "0",
+			Count: int64(limit + 1),
+		})
+	}
+	if _, err := pipe.Exec(timelineCtx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	lists := make([][]uint, len(authorIDs))
+	var missing []int
+	for i, cmd := range cmds {
+		zs := cmd.Val()
+		if len(zs) == 0 {
+			missing = append(missing, i)
+			continue
+		}
+
+		ids := make([]uint, 0, len(zs))
+		truncated := false
+		for _, z := range zs {
+			member := fmt.Sprint(z.Member)
+			if member == timelineTruncated {
+				truncated = true
+				continue
+			}
+			id64, err := strconv.ParseUint(member, 10, 64)
+			if err != nil || id64 == 0 {
+				continue
+			}
+			ids = append(ids, uint(id64))
+		}
+
+		page, err := timelinePage(db, authorIDs[i], ids, truncated, before, limit)
+		if err != nil {
+			return nil, err
+		}
+		lists[i] = page
+	}
+
+	if len(missing) > 0 {
+		if err := loadTimelines(db, authorIDs, missing, before, limit, lists); err != nil {
+			return nil, err
+		}
+	}
+	return lists, nil
+}
+
+// cuts the cached ids below the cursor to a page; when the timeline was
+// truncated and the page reaches past it, the rest comes from MySQL
+func timelinePage(db *gorm.DB, authorID uint, cached []uint, truncated bool, before uint64, limit int) ([]uint, error) {
+	if len(cached) >= limit {
+		return cached[:limit], nil
+	}
+	if !truncated {
+		return cached, nil
+	}
+
+	from := before
+	if len(cached) > 0 {
+		from = uint64(cached[len(cached)-1])
+	}
+	older, err := authorPostIDs(db, authorID, from, limit-len(cached))
+	if err != nil {
+		return nil, err
+	}
+	return append(cached, older...), nil
+}
+
+// post ids of one author below before, newest first
+func authorPostIDs(db *gorm.DB, authorID uint, before uint64, limit int) ([]uint, error) {
+	query := db.Model(&model.Post{}).Where("user_id = ?", authorID).Order("id DESC").Limit(limit)
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// serves the authors at the missing indexes from MySQL with one query of at
+// most limit ids each, then caches their full timelines in the background
+func loadTimelines(db *gorm.DB, authorIDs []uint, missing []int, before uint64, limit int, lists [][]uint) error {
+	ids := make([]uint, len(missing))
+	for i, idx := range missing {
+		ids[i] = authorIDs[idx]
+	}
+
+	byAuthor, err := newestPostIDs(db, ids, before, limit)
+	if err != nil {
+		return err
+	}
+	for _, idx := range missing {
+		lists[idx] = byAuthor[authorIDs[idx]]
+	}
+
+	go fillTimelines(db, ids)
+	return nil
+}
+
+// authors whose timeline is being filled, so concurrent misses query MySQL once
+var timelineFills sync.Map
+
+// caches up to timelineMaxLen post ids of each author that is not cached yet
+func fillTimelines(db *gorm.DB, authorIDs []uint) {
+	ids := make([]uint, 0, len(authorIDs))
+	for _, id := range authorIDs {
+		if _, busy := timelineFills.LoadOrStore(id, struct{}{}); !busy {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	defer func() {
+		for _, id := range ids {
+			timelineFills.Delete(id)
+		}
+	}()
+
+	byAuthor, err := newestPostIDs(db, ids, 0, timelineMaxLen+1)
+	if err != nil {
+		log.Printf("[warn] failed to load timelines of %d authors: %v\n", len(ids), err)
+		return
+	}
+
+	pipe := config.Rdb.Pipeline()
+	for _, authorID := range ids {
+		posts := byAuthor[authorID]
+
+		marker := timelineComplete
+		if len(posts) > timelineMaxLen {
+			posts = posts[:timelineMaxLen]
+			marker = timelineTruncated
+		}
+
+		zs := make([]redis.Z, 0, len(posts)+1)
+		zs = append(zs, redis.Z{Score: 0, Member: marker})
+		for _, id := range posts {
+			zs = append(zs, redis.Z{Score: float64(id), Member: id})
+		}
+		key := timelineKey(authorID)
+		pipe.Del(timelineCtx, key)
+		pipe.ZAdd(timelineCtx, key, zs...)
+		pipe.Expire(timelineCtx, key, timelineTTL)
+	}
+	_, _ = pipe.Exec(timelineCtx)
+}
+
+// the newest post ids (below before, 0 for no bound) of each author, at most
+// limit each and newest first, in one query
+func newestPostIDs(db *gorm.DB, authorIDs []uint, before uint64, limit int) (map[uint][]uint, error) {
+	var bound string
+	args := []interface{}{authorIDs}
+	if before > 0 {
+		bound = " AND id < ?"
+		args = append(args, before)
+	}
+	args = append(args, limit)
+
+	var rows []struct {
+		ID     uint
+		UserID uint
+	}
+	if err := db.Raw(`SELECT id, user_id FROM (
+		SELECT id, user_id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id DESC) AS rn
+		FROM posts WHERE user_id IN ? AND deleted_at IS NULL`+bound+`
+	) t WHERE rn <= ? ORDER BY user_id, id DESC`, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byAuthor := make(map[uint][]uint, len(authorIDs))
+	for _, r := range rows {
+		byAuthor[r.UserID] = append(byAuthor[r.UserID], r.ID)
+	}
+	return byAuthor, nil
+}
@@ -0,0 +1,168 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/metrics"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// account state read on every authenticated request and whenever a shared feed
+// is built: user:status:{uid} holds "{bits}:{role}", bits being a set of
+// restrictions (1 suspended, 2 shadow banned), with a short-lived L1 copy in
+// front that admin changes evict on every replica
+const (
+	userStatusPrefix = "user:status:"
+	userStatusTTL    = 10 * time.Minute
+
+	statusSuspended    = 1
+	statusShadowBanned = 2
+)
+
+var (
+	userStatusCtx = context.Background()
+	userStatusL1  = newLocalCache[UserStatus]("user_status", 10000, 10*time.Second)
+)
+
+type UserStatus struct {
+	Suspended    bool
+	ShadowBanned bool
+	Role         string // empty for unknown users
+}
+
+func userStatusKey(userID uint) string {
+	return fmt.Sprintf("%s%d", userStatusPrefix, userID)
+}
+
+func (s UserStatus) bits() int {
+	b := 0
+	if s.Suspended {
+		b |= statusSuspended
+	}
+	if s.ShadowBanned {
+		b |= statusShadowBanned
+	}
+	return b
+}
+
+func (s UserStatus) encode() string {
+	return strconv.Itoa(s.bits()) + ":" + s.Role
+}
+
+func decodeUserStatus(v string) (UserStatus, bool) {
+	bits, role, ok := strings.Cut(v, ":")
+	b, err := strconv.Atoi(bits)
+	if !ok || err != nil {
+		return UserStatus{}, false
+	}
+	return UserStatus{Suspended: b&statusSuspended != 0, ShadowBanned: b&statusShadowBanned != 0, Role: role}, true
+}
+
+// one user's restrictions and current role; unknown users have neither
+func GetUserStatus(db *gorm.DB, userID uint) (UserStatus, error) {
+	statuses, err := GetUserStatuses(db, []uint{userID})
+	if err != nil {
+		return UserStatus{}, err
+	}
+	return statuses[userID], nil
+}
+
+// statuses of many users through L1, one MGET and one query for the rest
+func GetUserStatuses(db *gorm.DB, userIDs []uint) (map[uint]UserStatus, error) {
+	result := make(map[uint]UserStatus, len(userIDs))
+	remote := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if _, done := result[id]; done {
+			continue
+		}
+		if s, ok := userStatusL1.Get(userStatusKey(id)); ok {
+			result[id] = s
+			continue
+		}
+		result[id] = UserStatus{}
+		remote = append(remote, id)
+	}
+	if len(remote) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(remote))
+	for i, id := range remote {
+		keys[i] = userStatusKey(id)
+	}
+	vals, err := config.Rdb.MGet(userStatusCtx, keys...).Result()
+	if err != nil {
+		vals = make([]interface{}, len(remote))
+	}
+
+	var missing []uint
+	for i, id := range remote {
+		v, _ := vals[i].(string)
+		status, ok := decodeUserStatus(v)
+		if !ok {
+			metrics.CacheRequestsTotal.WithLabelValues("user_status", "redis", "miss").Inc()
+			missing = append(missing, id)
+			continue
+		}
+		metrics.CacheRequestsTotal.WithLabelValues("user_status", "redis", "hit").Inc()
+		result[id] = status
+		userStatusL1.Set(keys[i], status)
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	var users []model.User
+	if err := db.Select("id", "role", "suspended_at", "shadow_banned_at").Where("id IN ?", missing).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		result[u.ID] = UserStatus{Suspended: u.SuspendedAt != nil, ShadowBanned: u.ShadowBannedAt != nil, Role: u.Role}
+	}
+
+	pipe := config.Rdb.Pipeline()
+	for _, id := range missing {
+		pipe.Set(userStatusCtx, userStatusKey(id), result[id].encode(), userStatusTTL)
+		userStatusL1.Set(userStatusKey(id), result[id])
+	}
+	_, _ = pipe.Exec(userStatusCtx)
+
+	return result, nil
+}
+
+// delete before write
+func DelUserStatus(userID uint) {
+	key := userStatusKey(userID)
+	_ = config.Rdb.Del(userStatusCtx, key).Err()
+	publishInvalidation(key)
+}
+
+// delete after write
+func DelUserStatusAsync(userID uint) {
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		DelUserStatus(userID)
+	}()
+}
+
+// ids of the shadow-banned users among userIDs
+func ShadowBannedAmong(db *gorm.DB, userIDs []uint) (map[uint]bool, error) {
+	statuses, err := GetUserStatuses(db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	banned := make(map[uint]bool)
+	for id, s := range statuses {
+		if s.ShadowBanned {
+			banned[id] = true
+		}
+	}
+	return banned, nil
+}
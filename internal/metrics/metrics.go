@@ -20,7 +20,49 @@ var HTTPRequestDuration = prometheus.NewHistogramVec(
 	[]string{"method", "path"},
 )
 
+var CacheRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cache lookups by cache, tier (l1/redis) and result (hit/miss).",
+	},
+	[]string{"cache", "tier", "result"},
+)
+
+var BloomFillRatio = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bloom_fill_ratio",
+		Help: "Fraction of bits set in a Bloom filter, averaged over its layers.",
+	},
+	[]string{"filter"},
+)
+
+var BloomFPRate = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bloom_estimated_fp_rate",
+		Help: "False-positive rate of a Bloom filter estimated from its fill ratio.",
+	},
+	[]string{"filter"},
+)
+
+var BloomLayers = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bloom_layers",
+		Help: "Number of layers of a scalable Bloom filter.",
+	},
+	[]string{"filter"},
+)
+
+var BloomItems = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bloom_items",
+		Help: "Number of items added to a Bloom filter since its last rebuild.",
+	},
+	[]string{"filter"},
+)
+
 func Init() {
 	prometheus.MustRegister(HTTPRequestsTotal)
 	prometheus.MustRegister(HTTPRequestDuration)
+	prometheus.MustRegister(CacheRequestsTotal)
+	prometheus.MustRegister(BloomFillRatio, BloomFPRate, BloomLayers, BloomItems)
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
 
@@ -24,9 +25,39 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
+// the current state of an account, which a token may no longer reflect
+type AccountStatus struct {
+	Suspended bool
+	Role      string
+}
+
+// looks up an account's current status; set once at startup, nil trusts the token
+var statusCheck func(userID uint) (AccountStatus, error)
+
+// tokens stay valid until they expire, so Auth asks check on every request to
+// turn suspended accounts away and apply role changes right after an admin acts
+func SetStatusCheck(check func(userID uint) (AccountStatus, error)) {
+	statusCheck = check
+}
+
 func Auth() gin.HandlerFunc {
+	return authenticate(false)
+}
+
+// for public routes that read differently for a signed-in user: without an
+// Authorization header the request goes on anonymously (no user_id), but a
+// token that is sent must be valid and its account not suspended
+func OptionalAuth() gin.HandlerFunc {
+	return authenticate(true)
+}
+
+func authenticate(optional bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
+		if optional && auth == "" {
+			c.Next()
+			return
+		}
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"msg": "missing or invalid token",
@@ -47,9 +78,48 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
+		role := claims.Role
+		if statusCheck != nil {
+			status, err := statusCheck(claims.UserID)
+			if err != nil {
+				// fail open on suspension, so an outage does not lock everyone out,
+				// but closed on privileges: without a current role RequireRole refuses
+				log.Printf("[warn] status check of user %d failed: %v\n", claims.UserID, err)
+				role = ""
+			} else {
+				if status.Suspended {
+					c.JSON(http.StatusForbidden, gin.H{
+						"msg": "account suspended",
+					})
+					c.Abort()
+					return
+				}
+				role = status.Role
+			}
+		}
+
 		c.Set("user_id", claims.UserID)
+		c.Set("role", role)
 
 		c.Next()
 
 	}
 }
+
+// lets the request through only when the user currently has one of roles; must run after Auth
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"msg": "permission denied",
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// one action taken through the admin API
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    uint      `gorm:"not null;index" json:"actor_id"`
+	Action     string    `gorm:"size:32;not null;index" json:"action"`
+	TargetType string    `gorm:"size:10;not null;default:'';index:idx_audit_target,priority:1" json:"target_type,omitempty"`
+	TargetID   uint      `gorm:"not null;default:0;index:idx_audit_target,priority:2" json:"target_id,omitempty"`
+	Detail     string    `gorm:"size:255;not null;default:''" json:"detail,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
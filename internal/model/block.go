@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// UserID blocked TargetID: neither sees the other and they cannot follow or interact
+type Block struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_blocks_user_created,priority:1" json:"user_id"`
+	TargetID  uint      `gorm:"primaryKey;autoIncrement:false;index:idx_blocks_target" json:"target_id"`
+	CreatedAt time.Time `gorm:"index:idx_blocks_user_created,priority:2" json:"created_at"`
+}
+
+// UserID muted TargetID: TargetID's posts are hidden from UserID's feeds only
+type Mute struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_mutes_user_created,priority:1" json:"user_id"`
+	TargetID  uint      `gorm:"primaryKey;autoIncrement:false" json:"target_id"`
+	CreatedAt time.Time `gorm:"index:idx_mutes_user_created,priority:2" json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// a post UserID saved for later; only visible to UserID
+type Bookmark struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_bookmarks_user_created,priority:1" json:"user_id"`
+	PostID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_bookmarks_post" json:"post_id"`
+	CreatedAt time.Time `gorm:"index:idx_bookmarks_user_created,priority:2" json:"created_at"`
+}
@@ -2,8 +2,9 @@ package model
 
 import "time"
 
+// the two composite indexes back the follow-time pagination of each side
 type Follow struct {
-	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
-	FollowID  uint      `gorm:"primaryKey;autoIncrement:false" json:"follow_id"`
-	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_follows_user_created,priority:1" json:"user_id"`
+	FollowID  uint      `gorm:"primaryKey;autoIncrement:false;index:idx_follows_follow_created,priority:1" json:"follow_id"`
+	CreatedAt time.Time `gorm:"index:idx_follows_user_created,priority:2;index:idx_follows_follow_created,priority:2" json:"created_at"`
 }
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// a pending follow of a private account, turned into a Follow when the target approves
+type FollowRequest struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_follow_requests_user_created,priority:1" json:"user_id"`
+	TargetID  uint      `gorm:"primaryKey;autoIncrement:false;index:idx_follow_requests_target_created,priority:1" json:"target_id"`
+	CreatedAt time.Time `gorm:"index:idx_follow_requests_user_created,priority:2;index:idx_follow_requests_target_created,priority:2" json:"created_at"`
+}
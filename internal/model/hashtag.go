@@ -0,0 +1,37 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// one #tag of a post, kept lowercase so tag filters are case-insensitive
+type PostHashtag struct {
+	Tag    string `gorm:"primaryKey;size:50" json:"tag"`
+	PostID uint   `gorm:"primaryKey;autoIncrement:false;index" json:"post_id"`
+}
+
+const (
+	MaxHashtagLen   = 50
+	MaxPostHashtags = 10
+)
+
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// the distinct hashtags of a post's content, lowercase and without '#', in order of appearance
+func ExtractHashtags(content string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, m := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		tag := strings.ToLower(m[1])
+		if seen[tag] || len([]rune(tag)) > MaxHashtagLen {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == MaxPostHashtags {
+			break
+		}
+	}
+	return tags
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// one like or unlike, kept so rankers can be evaluated offline
+type Interaction struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index:idx_interactions_user_created,priority:1" json:"user_id"`
+	PostID    uint      `gorm:"not null" json:"post_id"`
+	AuthorID  uint      `gorm:"not null" json:"author_id"`
+	Kind      string    `gorm:"size:16;not null" json:"kind"` // "like" or "unlike"
+	CreatedAt time.Time `gorm:"index:idx_interactions_user_created,priority:2" json:"created_at"`
+}
+
+// one candidate of a ranked feed snapshot with the features it was scored on
+type FeedImpression struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	SnapshotID string    `gorm:"size:32;not null;index" json:"snapshot_id"`
+	PostID     uint      `gorm:"not null" json:"post_id"`
+	AuthorID   uint      `gorm:"not null" json:"author_id"`
+	Position   int       `gorm:"not null" json:"position"`
+	AgeHours   float64   `gorm:"not null" json:"age_hours"`
+	Likes      int64     `gorm:"not null" json:"likes"`
+	Affinity   float64   `gorm:"not null" json:"affinity"`
+	FromHot    bool      `gorm:"not null" json:"from_hot"`
+	Score      float64   `gorm:"not null" json:"score"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// a named group of accounts whose posts can be read together without following them
+type List struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OwnerID     uint      `gorm:"not null;index" json:"owner_id"`
+	Name        string    `gorm:"size:50;not null" json:"name"`
+	Description string    `gorm:"size:160;not null;default:''" json:"description"`
+	IsPrivate   bool      `gorm:"not null;default:false" json:"is_private"` // visible to the owner only
+	MemberCount int64     `gorm:"not null;default:0" json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// the composite index backs the member pagination, idx_list_members_user the cleanup on block
+type ListMember struct {
+	ListID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_list_members_list_created,priority:1" json:"list_id"`
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index:idx_list_members_user" json:"user_id"`
+	CreatedAt time.Time `gorm:"index:idx_list_members_list_created,priority:2" json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+const (
+	ReportTargetPost = "post"
+	ReportTargetUser = "user"
+
+	CaseStatusPending  = "pending"
+	CaseStatusApproved = "approved" // reviewed and left as it is
+	CaseStatusRemoved  = "removed"  // the post was taken down or the profile cleared
+	CaseStatusWarned   = "warned"   // the author got a warning
+)
+
+// a reported post or user waiting in (or resolved from) the review queue; reports
+// on a target share its pending case, and reports after it was resolved open a new one
+type ModerationCase struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TargetType  string     `gorm:"size:10;not null;index:idx_cases_target,priority:1" json:"target_type"`
+	TargetID    uint       `gorm:"not null;index:idx_cases_target,priority:2" json:"target_id"`
+	Status      string     `gorm:"size:10;not null;index:idx_cases_status" json:"status"`
+	ReportCount int        `gorm:"not null;default:0" json:"report_count"`
+	ModeratorID uint       `gorm:"not null;default:0" json:"moderator_id,omitempty"` // who resolved it
+	Note        string     `gorm:"size:200;not null;default:''" json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// one user's report; a user reports a case at most once
+type Report struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CaseID     uint      `gorm:"not null;uniqueIndex:idx_reports_case_reporter,priority:1" json:"case_id"`
+	ReporterID uint      `gorm:"not null;uniqueIndex:idx_reports_case_reporter,priority:2" json:"reporter_id"`
+	Reason     string    `gorm:"size:200;not null;default:''" json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// a warning a moderator gave UserID when resolving a case
+type Warning struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	CaseID      uint      `gorm:"not null" json:"case_id"`
+	ModeratorID uint      `gorm:"not null" json:"moderator_id"`
+	Note        string    `gorm:"size:200;not null;default:''" json:"note"`
+	CreatedAt   time.Time `json:"created_at"`
+}
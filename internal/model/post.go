@@ -1,12 +1,20 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Post struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;index" json:"user_id"`
-	Content   string    `gorm:"type:text;not null" json:"content"`
-	ImageURL  string    `gorm:"type:varchar(255)" json:"image_url"`
-	LikeCount int       `gorm:"not null;default:0" json:"like_count"`
-	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"not null;index;index:idx_posts_user_pinned,priority:1" json:"user_id"`
+	Content   string         `gorm:"type:text;not null;index:idx_posts_content_ft,class:FULLTEXT" json:"content"`
+	ImageURL  string         `gorm:"type:varchar(255)" json:"image_url"`
+	LikeCount int            `gorm:"not null;default:0" json:"like_count"`
+	PinnedAt  *time.Time     `gorm:"index:idx_posts_user_pinned,priority:2" json:"pinned_at,omitempty"` // set while pinned to the top of the author's profile
+	CreatedAt time.Time      `gorm:"index" json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // set when a moderator removes the post
+
+	BookmarkedByMe bool `gorm:"-" json:"bookmarked_by_me"` // per viewer, filled in when posts are returned
 }
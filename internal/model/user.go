@@ -2,9 +2,30 @@ package model
 
 import "time"
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator" // works the review queue
+	RoleAdmin     = "admin"     // moderator rights plus the /admin API
+)
+
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
 type User struct {
-	ID        uint      `gorm:"primarykey;AUTO_INCREMENT" json:"id"`
-	Username  string    `gorm:"size:32;uniqueIndex;not null" json:"username"`
-	Password  string    `gorm:"size:128;not null" json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             uint       `gorm:"primarykey;AUTO_INCREMENT" json:"id"`
+	Username       string     `gorm:"size:32;uniqueIndex;not null" json:"username"`
+	Password       string     `gorm:"size:128;not null" json:"-"`
+	DisplayName    string     `gorm:"size:50;not null;default:''" json:"display_name"`
+	Bio            string     `gorm:"size:160;not null;default:''" json:"bio"`
+	AvatarURL      string     `gorm:"type:varchar(255);not null;default:''" json:"avatar_url"`
+	Website        string     `gorm:"type:varchar(255);not null;default:''" json:"website"`
+	Location       string     `gorm:"size:30;not null;default:''" json:"location"`
+	FollowerCount  int64      `gorm:"not null;default:0" json:"follower_count"`
+	FollowingCount int64      `gorm:"not null;default:0" json:"following_count"`
+	IsPrivate      bool       `gorm:"not null;default:false" json:"is_private"` // posts visible to approved followers only
+	Role           string     `gorm:"size:16;not null;default:'user'" json:"-"` // shown to the account itself only
+	SuspendedAt    *time.Time `json:"-"`                                        // set while an admin has suspended the account; shown to the account itself only
+	ShadowBannedAt *time.Time `json:"-"`                                        // set while shadow banned; never shown, not even to the user
+	CreatedAt      time.Time  `json:"created_at"`
 }
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// actions recorded in the audit log
+const (
+	AuditSuspendUser   = "suspend_user"
+	AuditUnsuspendUser = "unsuspend_user"
+	AuditSetRole       = "set_role"
+	AuditRemovePost    = "remove_post"
+	AuditResetLikes    = "reset_likes"
+	AuditRebuildHot    = "rebuild_hot"
+	AuditInspectInbox  = "inspect_inbox"
+	AuditShadowBan     = "shadow_ban"
+	AuditUnshadowBan   = "unshadow_ban"
+
+	MaxAuditReasonLen = 200
+)
+
+var (
+	ErrAdminSelf     = errors.New("cannot apply this to your own account")
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrInvalidReason = errors.New("invalid reason")
+)
+
+type AdminService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewAdminService(db *gorm.DB, rdb *redis.Client) *AdminService {
+	return &AdminService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+func writeAudit(db *gorm.DB, actorID uint, action, targetType string, targetID uint, detail string) error {
+	entry := model.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Detail:     detail,
+	}
+	return db.Create(&entry).Error
+}
+
+func validReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > MaxAuditReasonLen {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidReason, MaxAuditReasonLen)
+	}
+	return reason, nil
+}
+
+// locks the user's row for the rest of tx
+func lockUser(tx *gorm.DB, userID uint) (*model.User, error) {
+	var u model.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// a suspended user cannot log in and their tokens stop working at once; suspending twice is a no-op
+func (s *AdminService) SuspendUser(adminID, userID uint, reason string) error {
+	if adminID == userID {
+		return ErrAdminSelf
+	}
+	reason, err := validReason(reason)
+	if err != nil {
+		return err
+	}
+
+	return s.changeStatus(userID, func(tx *gorm.DB, u *model.User) error {
+		if u.SuspendedAt != nil {
+			return nil
+		}
+		if err := tx.Model(u).Update("suspended_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, adminID, AuditSuspendUser, model.ReportTargetUser, userID, reason)
+	})
+}
+
+func (s *AdminService) UnsuspendUser(adminID, userID uint) error {
+	return s.changeStatus(userID, func(tx *gorm.DB, u *model.User) error {
+		if u.SuspendedAt == nil {
+			return nil
+		}
+		if err := tx.Model(u).Update("suspended_at", nil).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, adminID, AuditUnsuspendUser, model.ReportTargetUser, userID, "")
+	})
+}
+
+// a shadow-banned user keeps posting, but nobody else gets their posts pushed or
+// sees them in the public feed, the hot list or search; posts already pushed
+// leave the followers' inboxes
+func (s *AdminService) ShadowBan(adminID, userID uint, reason string) error {
+	if adminID == userID {
+		return ErrAdminSelf
+	}
+	reason, err := validReason(reason)
+	if err != nil {
+		return err
+	}
+
+	banned := false
+	err = s.changeStatus(userID, func(tx *gorm.DB, u *model.User) error {
+		if u.ShadowBannedAt != nil {
+			return nil
+		}
+		if err := tx.Model(u).Update("shadow_banned_at", time.Now()).Error; err != nil {
+			return err
+		}
+		banned = true
+		return writeAudit(tx, adminID, AuditShadowBan, model.ReportTargetUser, userID, reason)
+	})
+	if err != nil || !banned {
+		return err
+	}
+
+	go func() {
+		if err := removeAuthorFromFollowerInboxes(s.db, s.rdb, userID); err != nil {
+			log.Printf("[warn] purge inboxes of shadow-banned user %d failed: %v\n", userID, err)
+		}
+	}()
+	return nil
+}
+
+// new posts fan out again; posts from the ban return to inboxes as they are rebuilt
+func (s *AdminService) UnshadowBan(adminID, userID uint) error {
+	return s.changeStatus(userID, func(tx *gorm.DB, u *model.User) error {
+		if u.ShadowBannedAt == nil {
+			return nil
+		}
+		if err := tx.Model(u).Update("shadow_banned_at", nil).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, adminID, AuditUnshadowBan, model.ReportTargetUser, userID, "")
+	})
+}
+
+// applies change to the locked user row, deleting the cached status and role around the write
+func (s *AdminService) changeStatus(userID uint, change func(tx *gorm.DB, u *model.User) error) error {
+	dao.DelUserStatus(userID)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		u, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		return change(tx, u)
+	})
+	if err != nil {
+		return err
+	}
+	dao.DelUserStatusAsync(userID)
+	return nil
+}
+
+// takes effect on the user's next request; Auth reads the role from the status cache, not the token
+func (s *AdminService) SetRole(adminID, userID uint, role string) error {
+	if adminID == userID {
+		return ErrAdminSelf
+	}
+	if !model.ValidRole(role) {
+		return fmt.Errorf("%w: must be %q, %q or %q", ErrInvalidRole, model.RoleUser, model.RoleModerator, model.RoleAdmin)
+	}
+
+	return s.changeStatus(userID, func(tx *gorm.DB, u *model.User) error {
+		old := u.Role
+		if old == role {
+			return nil
+		}
+		if err := tx.Model(u).Update("role", role).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, adminID, AuditSetRole, model.ReportTargetUser, userID, old+" -> "+role)
+	})
+}
+
+// takes a post down like a moderator's remove; pending cases about it are closed as removed
+func (s *AdminService) RemovePost(adminID, postID uint, reason string) error {
+	reason, err := validReason(reason)
+	if err != nil {
+		return err
+	}
+
+	var removed *model.Post
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		p, err := removePostTx(tx, postID)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return gorm.ErrRecordNotFound
+		}
+		removed = p
+
+		if err := tx.Model(&model.ModerationCase{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", model.ReportTargetPost, postID, model.CaseStatusPending).
+			Updates(map[string]interface{}{
+				"status":       model.CaseStatusRemoved,
+				"moderator_id": adminID,
+				"note":         reason,
+				"resolved_at":  time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, adminID, AuditRemovePost, model.ReportTargetPost, postID, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	propagatePostRemoval(s.db, s.rdb, *removed)
+	return nil
+}
+
+// clears every like of a post, e.g. after a burst of fake ones
+func (s *AdminService) ResetLikes(adminID, postID uint) error {
+	var post model.Post
+	if err := s.db.First(&post, postID).Error; err != nil {
+		return err
+	}
+	was := dao.LiveLikeCounts([]model.Post{post})[post.ID]
+	if err := dao.ResetPostLikes(s.db, post); err != nil {
+		return err
+	}
+	return writeAudit(s.db, adminID, AuditResetLikes, model.ReportTargetPost, postID, fmt.Sprintf("was %d", was))
+}
+
+// reseeds the hot rankings from MySQL and reloads the hot feed cache of every window
+func (s *AdminService) RebuildHotCache(adminID uint) error {
+	if err := dao.RebuildHotRanks(s.db); err != nil {
+		return err
+	}
+	dao.DelHotPostsCache()
+	if err := dao.RefreshHotPostsCache(s.db); err != nil {
+		return err
+	}
+	return writeAudit(s.db, adminID, AuditRebuildHot, "", 0, "")
+}
+
+// the newest entries of a user's push inbox as stored in Redis
+func (s *AdminService) InspectInbox(adminID, userID uint, limit int) (*InboxSnapshot, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if !dao.UserMayExist(userID) {
+		return nil, ErrUserNotFound
+	}
+	if err := s.db.Select("id").First(&model.User{}, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	snap, err := inspectInbox(context.Background(), s.rdb, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAudit(s.db, adminID, AuditInspectInbox, model.ReportTargetUser, userID, ""); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// audit entries newest first, optionally of one admin only (actorID 0 for all);
+// cursor is the id of the last entry of the previous page
+func (s *AdminService) ListAudit(actorID uint, limit int, cursor uint64) ([]model.AuditLog, uint64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Order("id DESC").Limit(limit)
+	if actorID > 0 {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var entries []model.AuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor uint64
+	if len(entries) == limit {
+		nextCursor = uint64(entries[len(entries)-1].ID)
+	}
+	return entries, nextCursor, nil
+}
+
+// grants admin to the given users at startup, so a fresh deployment has someone to run /admin
+func BootstrapAdmins(db *gorm.DB, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := db.Model(&model.User{}).Where("id IN ? AND role <> ?", userIDs, model.RoleAdmin).
+		Update("role", model.RoleAdmin).Error; err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		dao.DelUserStatus(id)
+	}
+	return nil
+}
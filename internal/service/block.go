@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBlockSelf = errors.New("cannot block yourself")
+	ErrMuteSelf  = errors.New("cannot mute yourself")
+)
+
+type BlockService struct {
+	db        *gorm.DB
+	rdb       *redis.Client
+	followSvc *FollowService
+}
+
+func NewBlockService(db *gorm.DB, rdb *redis.Client, followSvc *FollowService) *BlockService {
+	return &BlockService{
+		db:        db,
+		rdb:       rdb,
+		followSvc: followSvc,
+	}
+}
+
+func (s *BlockService) targetExists(targetID uint) error {
+	if !dao.UserMayExist(targetID) {
+		return ErrUserNotFound
+	}
+	var target model.User
+	if err := s.db.Select("id").Where("id = ?", targetID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// block: both follows, any pending requests and list memberships between the two
+// are removed, and each one's posts leave the other's inbox
+func (s *BlockService) Block(userID, targetID uint) error {
+	if userID == targetID {
+		return ErrBlockSelf
+	}
+	if err := s.targetExists(targetID); err != nil {
+		return err
+	}
+
+	b := model.Block{UserID: userID, TargetID: targetID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&b).Error; err != nil {
+		return err
+	}
+	dao.AddBlockToCache(userID, targetID)
+
+	if err := s.followSvc.UnFollow(userID, targetID); err != nil {
+		return err
+	}
+	if err := s.followSvc.UnFollow(targetID, userID); err != nil {
+		return err
+	}
+	if err := removeFromOwnedLists(s.db, userID, targetID); err != nil {
+		return err
+	}
+	if err := removeFromOwnedLists(s.db, targetID, userID); err != nil {
+		return err
+	}
+
+	go s.purgeInboxes(userID, targetID)
+
+	return nil
+}
+
+func (s *BlockService) purgeInboxes(userID, targetID uint) {
+	if err := removeAuthorFromInbox(s.db, s.rdb, userID, targetID); err != nil {
+		log.Printf("[warn] purge inbox of %d from %d failed: %v\n", userID, targetID, err)
+	}
+	if err := removeAuthorFromInbox(s.db, s.rdb, targetID, userID); err != nil {
+		log.Printf("[warn] purge inbox of %d from %d failed: %v\n", targetID, userID, err)
+	}
+}
+
+// unblock; the follows removed by the block are not restored
+func (s *BlockService) Unblock(userID, targetID uint) error {
+	if err := s.db.Where("user_id = ? AND target_id = ?", userID, targetID).Delete(&model.Block{}).Error; err != nil {
+		return err
+	}
+	dao.RemoveBlockFromCache(userID, targetID)
+	return nil
+}
+
+// mute: the target's posts disappear from my feeds, nothing else changes
+func (s *BlockService) Mute(userID, targetID uint) error {
+	if userID == targetID {
+		return ErrMuteSelf
+	}
+	if err := s.targetExists(targetID); err != nil {
+		return err
+	}
+
+	m := model.Mute{UserID: userID, TargetID: targetID}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&m).Error; err != nil {
+		return err
+	}
+	dao.AddMuteToCache(userID, targetID)
+	return nil
+}
+
+func (s *BlockService) Unmute(userID, targetID uint) error {
+	if err := s.db.Where("user_id = ? AND target_id = ?", userID, targetID).Delete(&model.Mute{}).Error; err != nil {
+		return err
+	}
+	dao.RemoveMuteFromCache(userID, targetID)
+	return nil
+}
+
+// users I blocked, newest first
+func (s *BlockService) ListBlocked(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "blocks", userID, "user_id", "target_id", limit, cursor)
+}
+
+// users I muted, newest first
+func (s *BlockService) ListMuted(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "mutes", userID, "user_id", "target_id", limit, cursor)
+}
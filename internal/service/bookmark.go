@@ -0,0 +1,132 @@
+package service
+
+import (
+	"log"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saves a post the user may see; saving twice is a no-op
+func (s *PostService) Bookmark(userID, postID uint) error {
+	if !dao.PostMayExist(postID) {
+		return gorm.ErrRecordNotFound
+	}
+	post, err := dao.GetPostByID(s.db, postID)
+	if err != nil {
+		return err
+	}
+	visible, err := canSeePost(s.db, userID, *post)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return gorm.ErrRecordNotFound
+	}
+
+	b := model.Bookmark{UserID: userID, PostID: postID}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&b).Error
+}
+
+// removing a bookmark that does not exist is a no-op, so a deleted post can still be unsaved
+func (s *PostService) Unbookmark(userID, postID uint) error {
+	return s.db.Where("user_id = ? AND post_id = ?", userID, postID).Delete(&model.Bookmark{}).Error
+}
+
+// my bookmarks, most recently saved first; cursor "<save time in unix micros>_<post id>".
+// bookmarks of deleted posts are dropped here, and posts hidden from me since saving are skipped
+func (s *PostService) ListBookmarks(userID uint, limit int, cursor string) ([]model.Post, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Model(&model.Bookmark{}).Where("user_id = ?", userID).
+		Order("created_at DESC").Order("post_id DESC").Limit(limit)
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at < ? OR (created_at = ? AND post_id < ?))", t, t, id)
+	}
+
+	var marks []model.Bookmark
+	if err := query.Find(&marks).Error; err != nil {
+		return nil, "", err
+	}
+	if len(marks) == 0 {
+		return []model.Post{}, "", nil
+	}
+
+	ids := make([]uint, len(marks))
+	for i, b := range marks {
+		ids[i] = b.PostID
+	}
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(posts) < len(ids) {
+		found := make(map[uint]bool, len(posts))
+		for _, p := range posts {
+			found[p.ID] = true
+		}
+		var gone []uint
+		for _, id := range ids {
+			if !found[id] {
+				gone = append(gone, id)
+			}
+		}
+		go func() {
+			if err := s.db.Where("post_id IN ?", gone).Delete(&model.Bookmark{}).Error; err != nil {
+				log.Printf("[warn] drop bookmarks of deleted posts %v failed: %v\n", gone, err)
+			}
+		}()
+	}
+
+	visible, err := filterPosts(s.db, userID, posts, false)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range visible {
+		visible[i].BookmarkedByMe = true
+	}
+
+	// the cursor follows the bookmark rows, so dropped posts do not stall paging
+	nextCursor := ""
+	if len(marks) == limit {
+		last := marks[len(marks)-1]
+		nextCursor = encodeFollowCursor(last.CreatedAt, last.PostID)
+	}
+	return visible, nextCursor, nil
+}
+
+// sets BookmarkedByMe on the posts viewerID saved; an anonymous viewer has none
+func markBookmarked(db *gorm.DB, viewerID uint, posts []model.Post) ([]model.Post, error) {
+	if viewerID == 0 || len(posts) == 0 {
+		return posts, nil
+	}
+
+	ids := make([]uint, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	var saved []uint
+	if err := db.Model(&model.Bookmark{}).Where("user_id = ? AND post_id IN ?", viewerID, ids).
+		Pluck("post_id", &saved).Error; err != nil {
+		return nil, err
+	}
+
+	isSaved := make(map[uint]bool, len(saved))
+	for _, id := range saved {
+		isSaved[id] = true
+	}
+	for i := range posts {
+		posts[i].BookmarkedByMe = isSaved[posts[i].ID]
+	}
+	return posts, nil
+}
@@ -2,82 +2,415 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"minifeed/internal/dao"
 	"minifeed/internal/model"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
-	ErrFollowSelf = errors.New("cannot follow yourself")
+	ErrFollowSelf            = errors.New("cannot follow yourself")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrFollowRequestNotFound = errors.New("follow request not found")
+	ErrBlocked               = errors.New("blocked")
 )
 
 type FollowService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	rdb *redis.Client
 }
 
-func NewFollowService(db *gorm.DB) *FollowService {
-	return &FollowService{db: db}
+func NewFollowService(db *gorm.DB, rdb *redis.Client) *FollowService {
+	return &FollowService{
+		db:  db,
+		rdb: rdb,
+	}
 }
 
-// follow
-func (s *FollowService) Follow(userID, targetID uint) error {
+// follow; a private target gets a pending request instead, reported by requested
+func (s *FollowService) Follow(userID, targetID uint) (requested bool, err error) {
 	if userID == targetID {
-		return ErrFollowSelf
+		return false, ErrFollowSelf
+	}
+
+	if !dao.UserMayExist(targetID) {
+		return false, ErrUserNotFound
+	}
+	blocked, err := dao.BlockedEitherWay(s.db, userID, []uint{targetID})
+	if err != nil {
+		return false, err
+	}
+	if blocked[0] {
+		return false, ErrBlocked
+	}
+
+	var target model.User
+	if err := s.db.Select("id", "is_private").Where("id = ?", targetID).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrUserNotFound
+		}
+		return false, err
 	}
 
+	if target.IsPrivate {
+		var count int64
+		if err := s.db.Model(&model.Follow{}).Where("user_id = ? AND follow_id = ?", userID, targetID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, nil
+		}
+
+		req := model.FollowRequest{UserID: userID, TargetID: targetID}
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&req).Error; err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, s.createFollow(userID, targetID)
+}
+
+// inserts the relation, moves the counters and backfills the follower's inbox
+func (s *FollowService) createFollow(userID, targetID uint) error {
+	created := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = createFollowTx(tx, userID, targetID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		s.followCreated(userID, targetID)
+	}
+	return nil
+}
+
+// the counters only move when the relation is actually created
+func createFollowTx(tx *gorm.DB, userID, targetID uint) (bool, error) {
 	f := model.Follow{
 		UserID:   userID,
 		FollowID: targetID,
 	}
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&f)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, adjustFollowCounts(tx, userID, targetID, 1)
+}
 
-	return s.db.FirstOrCreate(&f, "user_id = ? AND follow_id = ?", userID, targetID).Error
+// cache and inbox work once a new follow has committed
+func (s *FollowService) followCreated(userID, targetID uint) {
+	dao.AddFollowToCache(userID, targetID)
+	// the new following brings new friends-of-friends
+	dao.InvalidateSuggestions(userID)
+
+	go func() {
+		if err := backfillInbox(s.db, s.rdb, userID, targetID); err != nil {
+			log.Printf("[warn] backfill inbox of %d from %d failed: %v\n", userID, targetID, err)
+		}
+	}()
 }
 
-// unfollow
+// unfollow; also withdraws a pending request and clears the author from my inbox
 func (s *FollowService) UnFollow(userID, targetID uint) error {
-	return s.db.Where("user_id = ? AND follow_id = ?", userID, targetID).Delete(&model.Follow{}).Error
+	if err := s.db.Where("user_id = ? AND target_id = ?", userID, targetID).Delete(&model.FollowRequest{}).Error; err != nil {
+		return err
+	}
+
+	removed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND follow_id = ?", userID, targetID).Delete(&model.Follow{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		removed = true
+		return adjustFollowCounts(tx, userID, targetID, -1)
+	})
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return nil
+	}
+
+	dao.RemoveFollowFromCache(userID, targetID)
+
+	go func() {
+		if err := removeAuthorFromInbox(s.db, s.rdb, userID, targetID); err != nil {
+			log.Printf("[warn] clean inbox of %d from %d failed: %v\n", userID, targetID, err)
+		}
+	}()
+
+	return nil
 }
 
-// who I follow
-func (s *FollowService) ListFollowing(userID uint) ([]model.User, error) {
-	var rels []model.Follow
-	if err := s.db.Where("user_id = ?", userID).Find(&rels).Error; err != nil {
-		return nil, err
+// the target accepts requesterID's pending request; the request only goes away
+// together with the follow it turns into
+func (s *FollowService) ApproveRequest(targetID, requesterID uint) error {
+	created := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND target_id = ?", requesterID, targetID).Delete(&model.FollowRequest{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrFollowRequestNotFound
+		}
+
+		var err error
+		created, err = createFollowTx(tx, requesterID, targetID)
+		return err
+	})
+	if err != nil {
+		return err
 	}
-	if len(rels) == 0 {
-		return []model.User{}, nil
+	if created {
+		s.followCreated(requesterID, targetID)
 	}
+	return nil
+}
 
-	ids := make([]uint, 0, len(rels))
-	for _, r := range rels {
-		ids = append(ids, r.FollowID)
+// the target declines requesterID's pending request
+func (s *FollowService) RejectRequest(targetID, requesterID uint) error {
+	res := s.db.Where("user_id = ? AND target_id = ?", requesterID, targetID).Delete(&model.FollowRequest{})
+	if res.Error != nil {
+		return res.Error
 	}
+	if res.RowsAffected == 0 {
+		return ErrFollowRequestNotFound
+	}
+	return nil
+}
 
-	var users []model.User
-	if err := s.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
-		return nil, err
+// approves every pending request of a user, used when the account turns public
+func (s *FollowService) ApproveAllRequests(targetID uint) error {
+	var requesterIDs []uint
+	if err := s.db.Model(&model.FollowRequest{}).Where("target_id = ?", targetID).Pluck("user_id", &requesterIDs).Error; err != nil {
+		return err
+	}
+
+	// one transaction per request, so a failure keeps the rest pending
+	for _, id := range requesterIDs {
+		if err := s.ApproveRequest(targetID, id); err != nil && !errors.Is(err, ErrFollowRequestNotFound) {
+			return err
+		}
 	}
-	return users, nil
+	return nil
 }
 
-// my followers
-func (s *FollowService) ListFollowers(userID uint) ([]model.User, error) {
-	var rels []model.Follow
-	if err := s.db.Where("follow_id = ?", userID).Find(&rels).Error; err != nil {
+// pending requests sent to me, newest first
+func (s *FollowService) ListIncomingRequests(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "follow_requests", userID, "target_id", "user_id", limit, cursor)
+}
+
+// my pending requests, newest first
+func (s *FollowService) ListOutgoingRequests(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "follow_requests", userID, "user_id", "target_id", limit, cursor)
+}
+
+// relationship of the viewer with one target user
+type Relationship struct {
+	UserID     uint `json:"user_id"`
+	Following  bool `json:"following"`
+	FollowedBy bool `json:"followed_by"`
+	Mutual     bool `json:"mutual"`
+	Blocked    bool `json:"blocked"`
+	Muted      bool `json:"muted"`
+	Requested  bool `json:"requested"`
+}
+
+// at most this many targets per Relationships call
+const MaxRelationshipIDs = 100
+
+var ErrTooManyIDs = errors.New("too many ids")
+
+// relationship flags of userID with each target, in the order given
+func (s *FollowService) Relationships(userID uint, targetIDs []uint) ([]Relationship, error) {
+	if len(targetIDs) > MaxRelationshipIDs {
+		return nil, ErrTooManyIDs
+	}
+	if len(targetIDs) == 0 {
+		return []Relationship{}, nil
+	}
+
+	flags, err := dao.GetRelationFlags(s.db, userID, targetIDs)
+	if err != nil {
 		return nil, err
 	}
+
+	var pending []uint
+	if err := s.db.Model(&model.FollowRequest{}).
+		Where("user_id = ? AND target_id IN ?", userID, targetIDs).
+		Pluck("target_id", &pending).Error; err != nil {
+		return nil, err
+	}
+	requested := make(map[uint]bool, len(pending))
+	for _, id := range pending {
+		requested[id] = true
+	}
+
+	rels := make([]Relationship, len(targetIDs))
+	for i, id := range targetIDs {
+		rels[i] = Relationship{
+			UserID:     id,
+			Following:  flags.Following[i],
+			FollowedBy: flags.FollowedBy[i],
+			Mutual:     flags.Following[i] && flags.FollowedBy[i],
+			Blocked:    flags.Blocking[i],
+			Muted:      flags.Muting[i],
+			Requested:  requested[id],
+		}
+	}
+	return rels, nil
+}
+
+// moves following_count of the follower and follower_count of the followee by delta
+func adjustFollowCounts(tx *gorm.DB, userID, targetID uint, delta int) error {
+	if err := tx.Model(&model.User{}).Where("id = ? AND following_count + ? >= 0", userID, delta).
+		Update("following_count", gorm.Expr("following_count + ?", delta)).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.User{}).Where("id = ? AND follower_count + ? >= 0", targetID, delta).
+		Update("follower_count", gorm.Expr("follower_count + ?", delta)).Error
+}
+
+// following and follower counts of a user
+func (s *FollowService) FollowCounts(userID uint) (int64, int64, error) {
+	var u model.User
+	if err := s.db.Select("following_count", "follower_count").Where("id = ?", userID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, ErrUserNotFound
+		}
+		return 0, 0, err
+	}
+	return u.FollowingCount, u.FollowerCount, nil
+}
+
+// cursor "<follow time in unix micros>_<user id>" of the last relation of a page
+func encodeFollowCursor(t time.Time, id uint) string {
+	return fmt.Sprintf("%d_%d", t.UnixMicro(), id)
+}
+
+func decodeFollowCursor(cursor string) (time.Time, uint, error) {
+	parts := strings.SplitN(cursor, "_", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	us, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.UnixMicro(us), uint(id), nil
+}
+
+// one page of relations on one side of the follow graph (or of requests, blocks, mutes),
+// newest first; ownCol is the column holding userID, otherCol the user listed
+func listRelations(db *gorm.DB, table string, userID uint, ownCol, otherCol string, limit int, cursor string) ([]model.User, string, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := db.Table(table).Select(otherCol+" AS other_id", "created_at").
+		Where(ownCol+" = ?", userID).
+		Order("created_at DESC").Order(otherCol + " DESC").Limit(limit)
+	if cursor != "" {
+		t, id, err := decodeFollowCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("(created_at < ? OR (created_at = ? AND "+otherCol+" < ?))", t, t, id)
+	}
+
+	var rels []struct {
+		OtherID   uint
+		CreatedAt time.Time
+	}
+	if err := query.Scan(&rels).Error; err != nil {
+		return nil, "", err
+	}
 	if len(rels) == 0 {
-		return []model.User{}, nil
+		return []model.User{}, "", nil
 	}
 
 	ids := make([]uint, 0, len(rels))
 	for _, r := range rels {
-		ids = append(ids, r.UserID)
+		ids = append(ids, r.OtherID)
 	}
 
 	var users []model.User
-	if err := s.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
-		return nil, err
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, "", err
+	}
+
+	m := make(map[uint]model.User, len(users))
+	for _, u := range users {
+		m[u.ID] = u
+	}
+	ordered := make([]model.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := m[id]; ok {
+			ordered = append(ordered, u)
+		}
+	}
+
+	nextCursor := ""
+	if len(rels) == limit {
+		last := rels[len(rels)-1]
+		nextCursor = encodeFollowCursor(last.CreatedAt, last.OtherID)
+	}
+
+	return ordered, nextCursor, nil
+}
+
+// who I follow
+func (s *FollowService) ListFollowing(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "follows", userID, "user_id", "follow_id", limit, cursor)
+}
+
+// my followers
+func (s *FollowService) ListFollowers(userID uint, limit int, cursor string) ([]model.User, string, error) {
+	return listRelations(s.db, "follows", userID, "follow_id", "user_id", limit, cursor)
+}
+
+// switches the account between public and private; turning public
+// approves whatever requests were still pending
+func (s *FollowService) SetPrivate(userID uint, private bool) error {
+	res := s.db.Model(&model.User{}).Where("id = ?", userID).Update("is_private", private)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		if err := s.db.Model(&model.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrUserNotFound
+		}
+	}
+
+	if !private {
+		return s.ApproveAllRequests(userID)
 	}
-	return users, nil
+	return nil
 }
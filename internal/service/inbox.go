@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// push inbox layout: inbox:{uid} is a ZSet of post ids scored by creation time,
+// plus the sentinel member "0" at score 0 marking an inbox that exists but may be
+// empty. Writers only touch inboxes that exist; a missing one (never built, or
+// expired after inactivity) is rebuilt from MySQL on the owner's next read.
+const (
+	inboxPurgeBatch = 500
+	// posts of a newly followed author copied into the follower's inbox
+	inboxBackfillSize = 20
+	inboxSentinel     = "0"
+
+	DefaultInboxMaxLen = 1000
+	DefaultInboxTTL    = 7 * 24 * time.Hour
+)
+
+var (
+	inboxMu     sync.RWMutex
+	inboxMaxLen = DefaultInboxMaxLen
+	inboxTTL    = DefaultInboxTTL
+
+	inboxRebuilds singleflight.Group
+)
+
+// sets the inbox length cap and how long an unread inbox is kept; zero keeps the default
+func ConfigureInbox(maxLen int, ttl time.Duration) {
+	inboxMu.Lock()
+	defer inboxMu.Unlock()
+
+	if maxLen > 0 {
+		inboxMaxLen = maxLen
+	}
+	if ttl > 0 {
+		inboxTTL = ttl
+	}
+}
+
+func inboxLimits() (int, time.Duration) {
+	inboxMu.RLock()
+	defer inboxMu.RUnlock()
+	return inboxMaxLen, inboxTTL
+}
+
+func inboxKey(userID uint) string {
+	return dao.InboxKey(userID)
+}
+
+// ARGV: max length, TTL in seconds, sentinel, then score/member pairs; rank 0 is
+// the sentinel, so trimming starts at rank 1 and keeps the newest entries.
+// inboxes written before the cap may lack the sentinel or a TTL, and get them here
+var inboxAddScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+if not redis.call("ZSCORE", KEYS[1], ARGV[3]) then
+	redis.call("ZADD", KEYS[1], 0, ARGV[3])
+end
+if redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+for i = 4, #ARGV, 2 do
+	redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -(tonumber(ARGV[1]) + 1))
+return 1
+`)
+
+// adds posts to an inbox that exists, trimming it to the configured length
+func addToInbox(ctx context.Context, rdb redis.Scripter, userID uint, posts ...model.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	maxLen, ttl := inboxLimits()
+
+	args := make([]interface{}, 0, 3+2*len(posts))
+	args = append(args, maxLen, int64(ttl/time.Second), inboxSentinel)
+	for _, p := range posts {
+		args = append(args, p.CreatedAt.Unix(), p.ID)
+	}
+	return inboxAddScript.Run(ctx, rdb, []string{inboxKey(userID)}, args...).Err()
+}
+
+// fans one post out to many inboxes in a single pipeline
+func pushToInboxes(ctx context.Context, rdb *redis.Client, userIDs []uint, post model.Post) error {
+	maxLen, ttl := inboxLimits()
+
+	// EvalSha inside a pipeline cannot fall back to EVAL, so make sure the script is loaded
+	if err := inboxAddScript.Load(ctx, rdb).Err(); err != nil {
+		return err
+	}
+
+	pipe := rdb.Pipeline()
+	for _, uid := range userIDs {
+		inboxAddScript.EvalSha(ctx, pipe, []string{inboxKey(uid)}, maxLen, int64(ttl/time.Second), inboxSentinel, post.CreatedAt.Unix(), post.ID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// keeps the inbox of an active reader alive; false means it has to be rebuilt
+func touchInbox(ctx context.Context, rdb *redis.Client, userID uint) (bool, error) {
+	_, ttl := inboxLimits()
+	return rdb.Expire(ctx, inboxKey(userID), ttl).Result()
+}
+
+// rebuilds userID's inbox from the follow relations in MySQL; concurrent
+// reads of the same missing inbox share one rebuild
+func rebuildInbox(db *gorm.DB, rdb *redis.Client, userID uint) error {
+	_, err, _ := inboxRebuilds.Do(strconv.FormatUint(uint64(userID), 10), func() (interface{}, error) {
+		maxLen, ttl := inboxLimits()
+
+		var followed []uint
+		if err := db.Model(&model.Follow{}).Where("user_id = ?", userID).Pluck("follow_id", &followed).Error; err != nil {
+			return nil, err
+		}
+		// shadow-banned authors are never fanned out to others
+		banned, err := dao.ShadowBannedAmong(db, followed)
+		if err != nil {
+			return nil, err
+		}
+		authors := make([]uint, 0, len(followed)+1)
+		for _, id := range followed {
+			if !banned[id] {
+				authors = append(authors, id)
+			}
+		}
+		authors = append(authors, userID)
+
+		var posts []model.Post
+		if err := db.Select("id", "created_at").Where("user_id IN ?", authors).
+			Order("id DESC").Limit(maxLen).Find(&posts).Error; err != nil {
+			return nil, err
+		}
+
+		zs := make([]redis.Z, 0, len(posts)+1)
+		zs = append(zs, redis.Z{Score: 0, Member: inboxSentinel})
+		for _, p := range posts {
+			zs = append(zs, redis.Z{Score: float64(p.CreatedAt.Unix()), Member: p.ID})
+		}
+
+		ctx := context.Background()
+		key := inboxKey(userID)
+		pipe := rdb.TxPipeline()
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, zs...)
+		pipe.Expire(ctx, key, ttl)
+		_, err = pipe.Exec(ctx)
+		return nil, err
+	})
+	return err
+}
+
+// removes every post of authorID from userID's push inbox
+func removeAuthorFromInbox(db *gorm.DB, rdb *redis.Client, userID, authorID uint) error {
+	ctx := context.Background()
+	key := inboxKey(userID)
+
+	var batch []model.Post
+	return db.Select("id").Where("user_id = ?", authorID).
+		FindInBatches(&batch, inboxPurgeBatch, func(tx *gorm.DB, _ int) error {
+			members := make([]interface{}, len(batch))
+			for i, p := range batch {
+				members[i] = p.ID
+			}
+			return rdb.ZRem(ctx, key, members...).Err()
+		}).Error
+}
+
+// removes one post from the inboxes it was pushed to: its author's and every follower's
+func removePostFromInboxes(db *gorm.DB, rdb *redis.Client, post model.Post) error {
+	ctx := context.Background()
+	if err := rdb.ZRem(ctx, inboxKey(post.UserID), post.ID).Err(); err != nil {
+		return err
+	}
+
+	var after uint
+	for {
+		var followers []uint
+		if err := db.Model(&model.Follow{}).Where("follow_id = ? AND user_id > ?", post.UserID, after).
+			Order("user_id").Limit(inboxPurgeBatch).Pluck("user_id", &followers).Error; err != nil {
+			return err
+		}
+		if len(followers) == 0 {
+			return nil
+		}
+
+		pipe := rdb.Pipeline()
+		for _, uid := range followers {
+			pipe.ZRem(ctx, inboxKey(uid), post.ID)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		after = followers[len(followers)-1]
+	}
+}
+
+// what an inbox holds right now, for troubleshooting; reading it does not keep it alive
+type InboxSnapshot struct {
+	UserID  uint         `json:"user_id"`
+	Exists  bool         `json:"exists"`
+	TTL     int64        `json:"ttl_seconds"` // -1 without expiry
+	Size    int64        `json:"size"`        // posts, not counting the sentinel
+	Entries []InboxEntry `json:"entries"`     // newest first
+}
+
+type InboxEntry struct {
+	PostID   uint  `json:"post_id"`
+	PostedAt int64 `json:"posted_at"` // the score: the post's creation time in unix seconds
+}
+
+func inspectInbox(ctx context.Context, rdb *redis.Client, userID uint, limit int) (*InboxSnapshot, error) {
+	key := inboxKey(userID)
+	pipe := rdb.Pipeline()
+	ttlCmd := pipe.TTL(ctx, key)
+	sizeCmd := pipe.ZCount(ctx, key, "(0", "+inf")
+	rangeCmd := pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Max: "+inf", Min: "(0", Count: int64(limit)})
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	snap := &InboxSnapshot{UserID: userID, Entries: []InboxEntry{}}
+	// TTL reports -2 for a missing key
+	ttl := ttlCmd.Val()
+	if ttl == -2 {
+		return snap, nil
+	}
+	snap.Exists = true
+	snap.TTL = int64(ttl.Seconds())
+	if ttl < 0 {
+		snap.TTL = -1
+	}
+	snap.Size = sizeCmd.Val()
+	for _, z := range rangeCmd.Val() {
+		id64, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil || id64 == 0 {
+			continue
+		}
+		snap.Entries = append(snap.Entries, InboxEntry{PostID: uint(id64), PostedAt: int64(z.Score)})
+	}
+	return snap, nil
+}
+
+// removes authorID's posts from every follower's inbox, keeping the author's own;
+// no inbox holds more than the newest inboxMaxLen of them
+func removeAuthorFromFollowerInboxes(db *gorm.DB, rdb *redis.Client, authorID uint) error {
+	maxLen, _ := inboxLimits()
+
+	var postIDs []uint
+	if err := db.Model(&model.Post{}).Where("user_id = ?", authorID).
+		Order("id DESC").Limit(maxLen).Pluck("id", &postIDs).Error; err != nil {
+		return err
+	}
+	if len(postIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id
+	}
+
+	ctx := context.Background()
+	var after uint
+	for {
+		var followers []uint
+		if err := db.Model(&model.Follow{}).Where("follow_id = ? AND user_id > ?", authorID, after).
+			Order("user_id").Limit(inboxPurgeBatch).Pluck("user_id", &followers).Error; err != nil {
+			return err
+		}
+		if len(followers) == 0 {
+			return nil
+		}
+
+		pipe := rdb.Pipeline()
+		for _, uid := range followers {
+			pipe.ZRem(ctx, inboxKey(uid), members...)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		after = followers[len(followers)-1]
+	}
+}
+
+// copies authorID's latest posts into userID's push inbox
+func backfillInbox(db *gorm.DB, rdb *redis.Client, userID, authorID uint) error {
+	status, err := dao.GetUserStatus(db, authorID)
+	if err != nil {
+		return err
+	}
+	if status.ShadowBanned {
+		return nil
+	}
+
+	var posts []model.Post
+	if err := db.Select("id", "created_at").Where("user_id = ?", authorID).
+		Order("id DESC").Limit(inboxBackfillSize).Find(&posts).Error; err != nil {
+		return err
+	}
+
+	return addToInbox(context.Background(), rdb, userID, posts...)
+}
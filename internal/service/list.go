@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxListNameLen        = 50
+	MaxListDescriptionLen = 160
+	MaxListsPerUser       = 100
+	// members per list; every member's timeline is read for each feed page
+	MaxListMembers = 500
+)
+
+var (
+	ErrListNotFound  = errors.New("list not found")
+	ErrNotListOwner  = errors.New("not the owner of the list")
+	ErrInvalidList   = errors.New("invalid list")
+	ErrTooManyLists  = errors.New("too many lists")
+	ErrListFull      = errors.New("list is full")
+	ErrNotListMember = errors.New("not a member of the list")
+)
+
+type ListService struct {
+	db *gorm.DB
+}
+
+func NewListService(db *gorm.DB) *ListService {
+	return &ListService{db: db}
+}
+
+// fields a PATCH may change; nil leaves a field as it is
+type ListUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+func validListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxListNameLen {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidList, MaxListNameLen)
+	}
+	return name, nil
+}
+
+func validListDescription(desc string) (string, error) {
+	desc = strings.TrimSpace(desc)
+	if utf8.RuneCountInString(desc) > MaxListDescriptionLen {
+		return "", fmt.Errorf("%w: description longer than %d characters", ErrInvalidList, MaxListDescriptionLen)
+	}
+	return desc, nil
+}
+
+func (s *ListService) CreateList(ownerID uint, name, description string, private bool) (*model.List, error) {
+	name, err := validListName(name)
+	if err != nil {
+		return nil, err
+	}
+	description, err = validListDescription(description)
+	if err != nil {
+		return nil, err
+	}
+
+	l := model.List{OwnerID: ownerID, Name: name, Description: description, IsPrivate: private}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// the owner's row serializes concurrent creates, so the cap holds
+		if _, err := lockUser(tx, ownerID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.List{}).Where("owner_id = ?", ownerID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxListsPerUser {
+			return ErrTooManyLists
+		}
+		return tx.Create(&l).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// a list as viewerID may see it; someone else's private list does not exist for them
+func (s *ListService) GetList(viewerID, listID uint) (*model.List, error) {
+	var l model.List
+	if err := s.db.First(&l, listID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrListNotFound
+		}
+		return nil, err
+	}
+	if l.IsPrivate && l.OwnerID != viewerID {
+		return nil, ErrListNotFound
+	}
+	return &l, nil
+}
+
+// the list if ownerID owns it
+func (s *ListService) ownList(ownerID, listID uint) (*model.List, error) {
+	l, err := s.GetList(ownerID, listID)
+	if err != nil {
+		return nil, err
+	}
+	if l.OwnerID != ownerID {
+		return nil, ErrNotListOwner
+	}
+	return l, nil
+}
+
+// lists owned by ownerID, newest first; others only see the public ones
+func (s *ListService) ListLists(viewerID, ownerID uint) ([]model.List, error) {
+	query := s.db.Where("owner_id = ?", ownerID).Order("id DESC")
+	if viewerID != ownerID {
+		query = query.Where("is_private = ?", false)
+	}
+
+	lists := []model.List{}
+	if err := query.Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *ListService) UpdateList(ownerID, listID uint, req ListUpdate) (*model.List, error) {
+	if _, err := s.ownList(ownerID, listID); err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		name, err := validListName(*req.Name)
+		if err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		desc, err := validListDescription(*req.Description)
+		if err != nil {
+			return nil, err
+		}
+		updates["description"] = desc
+	}
+	if req.IsPrivate != nil {
+		updates["is_private"] = *req.IsPrivate
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&model.List{}).Where("id = ?", listID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.GetList(ownerID, listID)
+}
+
+func (s *ListService) DeleteList(ownerID, listID uint) error {
+	if _, err := s.ownList(ownerID, listID); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list_id = ?", listID).Delete(&model.ListMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.List{}, listID).Error
+	})
+}
+
+// adds userID to the owner's list; adding needs no follow, but a block either way refuses it
+func (s *ListService) AddMember(ownerID, listID, userID uint) error {
+	if _, err := s.ownList(ownerID, listID); err != nil {
+		return err
+	}
+
+	var u model.User
+	if err := s.db.Select("id").First(&u, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if userID != ownerID {
+		blocked, err := dao.BlockedEitherWay(s.db, ownerID, []uint{userID})
+		if err != nil {
+			return err
+		}
+		if blocked[0] {
+			return ErrBlocked
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// the list's row serializes concurrent adds, so the cap holds
+		var l model.List
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "member_count").First(&l, listID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrListNotFound
+			}
+			return err
+		}
+		if l.MemberCount >= MaxListMembers {
+			return ErrListFull
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ListMember{ListID: listID, UserID: userID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Model(&model.List{}).Where("id = ?", listID).
+			Update("member_count", gorm.Expr("member_count + 1")).Error
+	})
+}
+
+func (s *ListService) RemoveMember(ownerID, listID, userID uint) error {
+	if _, err := s.ownList(ownerID, listID); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("list_id = ? AND user_id = ?", listID, userID).Delete(&model.ListMember{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotListMember
+		}
+		return tx.Model(&model.List{}).Where("id = ? AND member_count > 0", listID).
+			Update("member_count", gorm.Expr("member_count - 1")).Error
+	})
+}
+
+// members of a list, most recently added first
+func (s *ListService) ListMembers(viewerID, listID uint, limit int, cursor string) ([]model.User, string, error) {
+	if _, err := s.GetList(viewerID, listID); err != nil {
+		return nil, "", err
+	}
+	return listRelations(s.db, "list_members", listID, "list_id", "user_id", limit, cursor)
+}
+
+// posts of a list's members, merged from the authors' cached timelines like the pull feed;
+// members the viewer may not see (private, blocked, muted or shadow banned) are filtered out
+func (s *ListService) ListFeed(viewerID, listID uint, limit int, cursor uint64) ([]model.Post, uint64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	if _, err := s.GetList(viewerID, listID); err != nil {
+		return nil, 0, err
+	}
+
+	var members []uint
+	if err := s.db.Model(&model.ListMember{}).Where("list_id = ?", listID).Pluck("user_id", &members).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(members) == 0 {
+		return []model.Post{}, 0, nil
+	}
+
+	lists, err := dao.AuthorTimelines(s.db, members, cursor, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := mergeNewest(lists, limit)
+	if len(ids) == 0 {
+		return []model.Post{}, 0, nil
+	}
+
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, err = visiblePosts(s.db, viewerID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, err = hideShadowBanned(s.db, viewerID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, err = markBookmarked(s.db, viewerID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the cursor follows the merged ids, so hidden posts do not stall paging
+	return posts, uint64(ids[len(ids)-1]), nil
+}
+
+// drops userID from ownerID's lists, e.g. after one of them blocked the other
+func removeFromOwnedLists(db *gorm.DB, ownerID, userID uint) error {
+	owned := db.Model(&model.List{}).Select("id").Where("owner_id = ?", ownerID)
+
+	var listIDs []uint
+	if err := db.Model(&model.ListMember{}).Where("user_id = ? AND list_id IN (?)", userID, owned).
+		Pluck("list_id", &listIDs).Error; err != nil {
+		return err
+	}
+	if len(listIDs) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND list_id IN ?", userID, listIDs).Delete(&model.ListMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.List{}).Where("id IN ? AND member_count > 0", listIDs).
+			Update("member_count", gorm.Expr("member_count - 1")).Error
+	})
+}
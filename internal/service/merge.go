@@ -0,0 +1,52 @@
+package service
+
+import "container/heap"
+
+// head of one newest-first id list during a k-way merge
+type mergeHead struct {
+	list int
+	pos  int
+	id   uint
+}
+
+// max-heap on id
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].id > h[j].id }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeHead)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// merges newest-first id lists into the newest limit ids overall, dropping
+// duplicates; costs O(k + limit·log k) for k lists
+func mergeNewest(lists [][]uint, limit int) []uint {
+	h := make(mergeHeap, 0, len(lists))
+	for i, l := range lists {
+		if len(l) > 0 {
+			h = append(h, mergeHead{list: i, pos: 0, id: l[0]})
+		}
+	}
+	heap.Init(&h)
+
+	merged := make([]uint, 0, limit)
+	for h.Len() > 0 && len(merged) < limit {
+		top := h[0]
+		if len(merged) == 0 || merged[len(merged)-1] != top.id {
+			merged = append(merged, top.id)
+		}
+
+		if next := top.pos + 1; next < len(lists[top.list]) {
+			h[0] = mergeHead{list: top.list, pos: next, id: lists[top.list][next]}
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return merged
+}
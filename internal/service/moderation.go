@@ -0,0 +1,448 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+	"minifeed/pkg/ahocorasick"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ModerationApprove = "approve"
+	ModerationRemove  = "remove"
+	ModerationWarn    = "warn"
+
+	MaxReportReasonLen   = 200
+	MaxModerationNoteLen = 200
+	// latest reports shown with each case of the review queue
+	moderationReportsShown = 5
+)
+
+var (
+	ErrBannedContent     = errors.New("content contains banned terms")
+	ErrInvalidReport     = errors.New("invalid report")
+	ErrReportSelf        = errors.New("cannot report yourself")
+	ErrAlreadyReported   = errors.New("already reported")
+	ErrCaseNotFound      = errors.New("case not found")
+	ErrCaseResolved      = errors.New("case already resolved")
+	ErrInvalidAction     = errors.New("invalid moderation action")
+	ErrInvalidCaseStatus = errors.New("invalid case status")
+)
+
+var bannedTerms atomic.Pointer[ahocorasick.Matcher]
+
+// replaces the terms new posts are checked against; an empty list turns the check off.
+// terms match as whole words, so a banned "ass" does not reject "class"
+func SetBannedTerms(terms []string) int {
+	m := ahocorasick.NewWholeWord(terms)
+	bannedTerms.Store(m)
+	return m.Len()
+}
+
+// ErrBannedContent when text contains a banned term
+func checkBannedTerms(text string) error {
+	if m := bannedTerms.Load(); m != nil && m.Contains(text) {
+		return ErrBannedContent
+	}
+	return nil
+}
+
+type ModerationService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewModerationService(db *gorm.DB, rdb *redis.Client) *ModerationService {
+	return &ModerationService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// a case of the review queue with what was reported
+type ModerationItem struct {
+	model.ModerationCase
+	Post    *model.Post    `json:"post,omitempty"` // the reported post, also once removed
+	User    *model.User    `json:"user,omitempty"` // the reported user, or the post's author
+	Reports []model.Report `json:"reports"`        // latest first
+}
+
+// reports a post the reporter can see, or another user; the report joins the
+// target's pending case, which is opened on the first report
+func (s *ModerationService) Report(reporterID uint, targetType string, targetID uint, reason string) (*model.ModerationCase, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > MaxReportReasonLen {
+		return nil, fmt.Errorf("%w: reason longer than %d characters", ErrInvalidReport, MaxReportReasonLen)
+	}
+
+	switch targetType {
+	case model.ReportTargetPost:
+		if err := s.checkReportablePost(reporterID, targetID); err != nil {
+			return nil, err
+		}
+	case model.ReportTargetUser:
+		if reporterID == targetID {
+			return nil, ErrReportSelf
+		}
+		if !dao.UserMayExist(targetID) {
+			return nil, ErrUserNotFound
+		}
+	default:
+		return nil, fmt.Errorf("%w: target_type must be %q or %q", ErrInvalidReport, model.ReportTargetPost, model.ReportTargetUser)
+	}
+
+	var mc model.ModerationCase
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// the target's row serializes concurrent reports, so one pending case is opened
+		if err := lockReportTarget(tx, targetType, targetID); err != nil {
+			return err
+		}
+
+		err := tx.Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, model.CaseStatusPending).
+			First(&mc).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			mc = model.ModerationCase{TargetType: targetType, TargetID: targetID, Status: model.CaseStatusPending}
+			err = tx.Create(&mc).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		r := model.Report{CaseID: mc.ID, ReporterID: reporterID, Reason: reason}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&r)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrAlreadyReported
+		}
+
+		mc.ReportCount++
+		return tx.Model(&mc).Update("report_count", gorm.Expr("report_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &mc, nil
+}
+
+func (s *ModerationService) checkReportablePost(reporterID, postID uint) error {
+	if !dao.PostMayExist(postID) {
+		return gorm.ErrRecordNotFound
+	}
+	post, err := dao.GetPostByID(s.db, postID)
+	if err != nil {
+		return err
+	}
+	if post.UserID == reporterID {
+		return ErrReportSelf
+	}
+	visible, err := canSeePost(s.db, reporterID, *post)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func lockReportTarget(tx *gorm.DB, targetType string, targetID uint) error {
+	locking := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id")
+	var err error
+	if targetType == model.ReportTargetPost {
+		err = locking.First(&model.Post{}, targetID).Error
+	} else {
+		err = locking.First(&model.User{}, targetID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) && targetType == model.ReportTargetUser {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// one page of the review queue; pending cases oldest first, resolved ones
+// latest first. cursor is the id of the last case of the previous page
+func (s *ModerationService) ListCases(status string, limit int, cursor uint64) ([]ModerationItem, uint64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Where("status = ?", status).Limit(limit)
+	switch status {
+	case model.CaseStatusPending:
+		query = query.Order("id ASC")
+		if cursor > 0 {
+			query = query.Where("id > ?", cursor)
+		}
+	case model.CaseStatusApproved, model.CaseStatusRemoved, model.CaseStatusWarned:
+		query = query.Order("id DESC")
+		if cursor > 0 {
+			query = query.Where("id < ?", cursor)
+		}
+	default:
+		return nil, 0, ErrInvalidCaseStatus
+	}
+
+	var cases []model.ModerationCase
+	if err := query.Find(&cases).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(cases) == 0 {
+		return []ModerationItem{}, 0, nil
+	}
+
+	items, err := s.caseItems(cases)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor uint64
+	if len(cases) == limit {
+		nextCursor = uint64(cases[len(cases)-1].ID)
+	}
+	return items, nextCursor, nil
+}
+
+// attaches the reported posts and users and the latest reports to cases
+func (s *ModerationService) caseItems(cases []model.ModerationCase) ([]ModerationItem, error) {
+	caseIDs := make([]uint, len(cases))
+	var postIDs, userIDs []uint
+	for i, mc := range cases {
+		caseIDs[i] = mc.ID
+		if mc.TargetType == model.ReportTargetPost {
+			postIDs = append(postIDs, mc.TargetID)
+		} else {
+			userIDs = append(userIDs, mc.TargetID)
+		}
+	}
+
+	posts := make(map[uint]*model.Post, len(postIDs))
+	if len(postIDs) > 0 {
+		var rows []model.Post
+		if err := s.db.Unscoped().Where("id IN ?", postIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			posts[rows[i].ID] = &rows[i]
+			userIDs = append(userIDs, rows[i].UserID)
+		}
+	}
+
+	users := make(map[uint]*model.User, len(userIDs))
+	if len(userIDs) > 0 {
+		var rows []model.User
+		if err := s.db.Where("id IN ?", userIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			users[rows[i].ID] = &rows[i]
+		}
+	}
+
+	var reports []model.Report
+	if err := s.db.Raw(`SELECT id, case_id, reporter_id, reason, created_at FROM (
+		SELECT *, ROW_NUMBER() OVER (PARTITION BY case_id ORDER BY id DESC) AS rn
+		FROM reports WHERE case_id IN ?
+	) t WHERE rn <= ? ORDER BY case_id, id DESC`, caseIDs, moderationReportsShown).Scan(&reports).Error; err != nil {
+		return nil, err
+	}
+	byCase := make(map[uint][]model.Report, len(cases))
+	for _, r := range reports {
+		byCase[r.CaseID] = append(byCase[r.CaseID], r)
+	}
+
+	items := make([]ModerationItem, len(cases))
+	for i, mc := range cases {
+		item := ModerationItem{ModerationCase: mc, Reports: byCase[mc.ID]}
+		if item.Reports == nil {
+			item.Reports = []model.Report{}
+		}
+		if mc.TargetType == model.ReportTargetPost {
+			item.Post = posts[mc.TargetID]
+			if item.Post != nil {
+				item.User = users[item.Post.UserID]
+			}
+		} else {
+			item.User = users[mc.TargetID]
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// resolves a pending case: approve leaves the target as it is, remove takes the
+// post down (or clears the reported profile), warn records a warning for the author
+func (s *ModerationService) Resolve(moderatorID, caseID uint, action, note string) (*model.ModerationCase, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxModerationNoteLen {
+		return nil, fmt.Errorf("%w: note longer than %d characters", ErrInvalidAction, MaxModerationNoteLen)
+	}
+
+	var status string
+	switch action {
+	case ModerationApprove:
+		status = model.CaseStatusApproved
+	case ModerationRemove:
+		status = model.CaseStatusRemoved
+	case ModerationWarn:
+		status = model.CaseStatusWarned
+	default:
+		return nil, fmt.Errorf("%w: action must be %q, %q or %q", ErrInvalidAction, ModerationApprove, ModerationRemove, ModerationWarn)
+	}
+
+	var mc model.ModerationCase
+	var removed *model.Post
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&mc, caseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCaseNotFound
+			}
+			return err
+		}
+		if mc.Status != model.CaseStatusPending {
+			return ErrCaseResolved
+		}
+
+		switch action {
+		case ModerationRemove:
+			if mc.TargetType == model.ReportTargetPost {
+				p, err := removePostTx(tx, mc.TargetID)
+				if err != nil {
+					return err
+				}
+				removed = p
+			} else if err := clearProfileTx(tx, mc.TargetID); err != nil {
+				return err
+			}
+		case ModerationWarn:
+			userID, err := caseAuthor(tx, mc)
+			if err != nil {
+				return err
+			}
+			w := model.Warning{UserID: userID, CaseID: mc.ID, ModeratorID: moderatorID, Note: note}
+			if err := tx.Create(&w).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		mc.Status = status
+		mc.ModeratorID = moderatorID
+		mc.Note = note
+		mc.ResolvedAt = &now
+		return tx.Model(&mc).Updates(map[string]interface{}{
+			"status":       mc.Status,
+			"moderator_id": mc.ModeratorID,
+			"note":         mc.Note,
+			"resolved_at":  mc.ResolvedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if removed != nil {
+		propagatePostRemoval(s.db, s.rdb, *removed)
+	}
+	return &mc, nil
+}
+
+// the user a warning goes to: the reported user, or the author of the reported post
+func caseAuthor(tx *gorm.DB, mc model.ModerationCase) (uint, error) {
+	if mc.TargetType == model.ReportTargetUser {
+		return mc.TargetID, nil
+	}
+	var post model.Post
+	if err := tx.Unscoped().Select("id", "user_id").First(&post, mc.TargetID).Error; err != nil {
+		return 0, err
+	}
+	return post.UserID, nil
+}
+
+// soft-deletes a post with its pin and bookmarks; nil when it is already gone
+func removePostTx(tx *gorm.DB, postID uint) (*model.Post, error) {
+	var post model.Post
+	if err := tx.First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	dao.DelPostCache(postID)
+	if err := tx.Model(&post).Update("pinned_at", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Delete(&post).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("post_id = ?", postID).Delete(&model.Bookmark{}).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// clears what a user wrote about themselves; the account itself stays
+func clearProfileTx(tx *gorm.DB, userID uint) error {
+	return tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"display_name": "",
+		"bio":          "",
+		"avatar_url":   "",
+		"website":      "",
+		"location":     "",
+	}).Error
+}
+
+// takes a removed post out of every cache and index it was copied into
+func propagatePostRemoval(db *gorm.DB, rdb *redis.Client, post model.Post) {
+	dao.DelPostCacheAsync(post.ID)
+
+	dao.RemovePostFromTimeline(post)
+
+	dao.DelHotPostsCache()
+	dao.RemovePostFromHotRank(post.ID)
+	dao.DelHotPostsCacheAsync()
+
+	dao.RemovePostFromIndex(db, post.ID)
+
+	go func() {
+		if err := removePostFromInboxes(db, rdb, post); err != nil {
+			log.Printf("[warn] remove post %d from inboxes failed: %v\n", post.ID, err)
+		}
+	}()
+}
+
+// warnings I received, newest first; cursor is the id of the last warning of the previous page
+func (s *ModerationService) ListWarnings(userID uint, limit int, cursor uint64) ([]model.Warning, uint64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	query := s.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var warnings []model.Warning
+	if err := query.Find(&warnings).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor uint64
+	if len(warnings) == limit {
+		nextCursor = uint64(warnings[len(warnings)-1].ID)
+	}
+	return warnings, nextCursor, nil
+}
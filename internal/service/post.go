@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"minifeed/internal/dao"
 	"minifeed/internal/model"
 	"strconv"
@@ -25,6 +26,10 @@ func NewPostService(db *gorm.DB, rdb *redis.Client) *PostService {
 
 // create posts
 func (s *PostService) CreatePost(userID uint, content, imageURL string) (*model.Post, error) {
+	if err := checkBannedTerms(content); err != nil {
+		return nil, err
+	}
+
 	post := model.Post{
 		UserID:   userID,
 		Content:  content,
@@ -36,23 +41,33 @@ func (s *PostService) CreatePost(userID uint, content, imageURL string) (*model.
 	}
 
 	dao.AddPostToBloom(post.ID)
+	dao.DelPostCache(post.ID)
 
+	dao.AddPostToHotRank(post)
 	dao.DelHotPostsCache()
 
+	dao.AddPostToTimeline(post)
+	dao.IndexPost(s.db, post)
+
 	go s.pushPostInbox(post)
 
 	return &post, nil
 
 }
 
-// public: newest first + cursor-based pagination
-func (s *PostService) ListPublicPosts(limit int, cursor uint64) ([]model.Post, uint64, error) {
+// public: newest first + cursor-based pagination; viewerID 0 = anonymous reader
+func (s *PostService) ListPublicPosts(viewerID uint, limit int, cursor uint64) ([]model.Post, uint64, error) {
 	if limit <= 10 || limit > 100 {
 		limit = 10
 	}
 
+	// private and shadow-banned accounts are left out, except a shadow-banned
+	// reader still sees their own posts
+	hiddenAuthors := s.db.Model(&model.User{}).Select("id").
+		Where("is_private = ? OR (shadow_banned_at IS NOT NULL AND id <> ?)", true, viewerID)
+
 	var posts []model.Post
-	query := s.db.Order("id DESC").Limit(limit)
+	query := s.db.Where("user_id NOT IN (?)", hiddenAuthors).Order("id DESC").Limit(limit)
 	if cursor > 0 {
 		query = query.Where("id < ?", cursor)
 	}
@@ -66,45 +81,88 @@ func (s *PostService) ListPublicPosts(limit int, cursor uint64) ([]model.Post, u
 		nextCursor = uint64(posts[len(posts)-1].ID)
 	}
 
-	return posts, nextCursor, nil
+	if viewerID == 0 {
+		return posts, nextCursor, nil
+	}
 
+	// the cursor is taken before filtering, so a short page does not end the feed
+	posts, err := visiblePosts(s.db, viewerID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, err = markBookmarked(s.db, viewerID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, nextCursor, nil
 }
 
-// pull mode: posts from users I follow
+// pull mode: posts from users I follow, merged from the authors' cached timelines
 func (s *PostService) ListFollowFeed(userID uint, limit int, cursor uint64) ([]model.Post, uint64, error) {
 	if limit < 0 || limit > 100 {
 		limit = 10
 	}
 
-	var rels []model.Follow
-	if err := s.db.Where("user_id = ?", userID).Find(&rels).Error; err != nil {
+	authors, err := s.followFeedAuthors(userID)
+	if err != nil {
 		return nil, 0, err
 	}
-	if len(rels) == 0 {
+	if len(authors) == 0 {
 		return []model.Post{}, 0, nil
 	}
 
-	ids := make([]uint, 0, len(rels))
-	for _, r := range rels {
-		ids = append(ids, r.FollowID)
+	// each author contributes at most one page, so the merge never looks past limit
+	lists, err := dao.AuthorTimelines(s.db, authors, cursor, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := mergeNewest(lists, limit)
+	if len(ids) == 0 {
+		return []model.Post{}, 0, nil
 	}
 
-	var posts []model.Post
-	query := s.db.Where("user_id IN ?", ids).Order("id DESC").Limit(limit)
-	if cursor > 0 {
-		query = query.Where("id < ?", cursor)
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, 0, err
 	}
-	if err := query.Find(&posts).Error; err != nil {
+	posts, err = hideShadowBanned(s.db, userID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	posts, err = markBookmarked(s.db, userID, posts)
+	if err != nil {
 		return nil, 0, err
 	}
 
-	var nextCursor uint64
-	if len(posts) > 0 {
-		nextCursor = uint64(posts[len(posts)-1].ID)
+	// the cursor follows the merged ids, so a missing or hidden post does not stall paging
+	return posts, uint64(ids[len(ids)-1]), nil
+}
+
+// authors of my pull feed: everyone I follow except muted users
+func (s *PostService) followFeedAuthors(userID uint) ([]uint, error) {
+	// follows of private accounts only exist once approved and a block removes
+	// the follow both ways, so only muted authors need leaving out here
+	var rels []model.Follow
+	if err := s.db.Where("user_id = ?", userID).Find(&rels).Error; err != nil {
+		return nil, err
 	}
 
-	return posts, nextCursor, nil
+	var muted []uint
+	if err := s.db.Model(&model.Mute{}).Where("user_id = ?", userID).Pluck("target_id", &muted).Error; err != nil {
+		return nil, err
+	}
+	isMuted := make(map[uint]bool, len(muted))
+	for _, id := range muted {
+		isMuted[id] = true
+	}
 
+	ids := make([]uint, 0, len(rels))
+	for _, r := range rels {
+		if !isMuted[r.FollowID] {
+			ids = append(ids, r.FollowID)
+		}
+	}
+	return ids, nil
 }
 
 // push mode: read one post from inbox ZSet
@@ -114,16 +172,20 @@ func (s *PostService) ListInboxFeed(userID uint, limit int, cursor string) ([]mo
 	}
 
 	ctx := context.Background()
-	inboxKey := fmt.Sprintf("inbox:%d", userID)
+	key := inboxKey(userID)
+
+	if err := s.ensureInbox(ctx, userID); err != nil {
+		return nil, "", err
+	}
 
 	max := "+inf"
 	if cursor != "" {
 		max = "(" + cursor
 	}
 
-	zs, err := s.rdb.ZRevRangeByScoreWithScores(ctx, inboxKey, &redis.ZRangeBy{
+	zs, err := s.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
 		Max:    max,
-		Min:    "0",
+		Min:    "(0", // skips the sentinel
 		Offset: 0,
 		Count:  int64(limit),
 	}).Result()
@@ -149,20 +211,18 @@ func (s *PostService) ListInboxFeed(userID uint, limit int, cursor string) ([]mo
 		return []model.Post{}, "", nil
 	}
 
-	var posts []model.Post
-	if err := s.db.Where("id IN ?", ids).Find(&posts).Error; err != nil {
+	ordered, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
 		return nil, "", err
 	}
-
-	m := make(map[uint]model.Post, len(posts))
-	for _, p := range posts {
-		m[p.ID] = p
+	// entries of private authors I no longer follow, blocked or muted authors may linger here
+	ordered, err = visiblePosts(s.db, userID, ordered)
+	if err != nil {
+		return nil, "", err
 	}
-	ordered := make([]model.Post, 0, len(ids))
-	for _, id := range ids {
-		if p, ok := m[id]; ok {
-			ordered = append(ordered, p)
-		}
+	ordered, err = markBookmarked(s.db, userID, ordered)
+	if err != nil {
+		return nil, "", err
 	}
 
 	nextCursor := ""
@@ -174,24 +234,41 @@ func (s *PostService) ListInboxFeed(userID uint, limit int, cursor string) ([]mo
 
 }
 
+// reading keeps the inbox alive; a missing one is rebuilt from MySQL
+func (s *PostService) ensureInbox(ctx context.Context, userID uint) error {
+	exists, err := touchInbox(ctx, s.rdb, userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return rebuildInbox(s.db, s.rdb, userID)
+	}
+	return nil
+}
+
 // like and unlike
 func (s *PostService) ToggleLike(userID, postID uint) (bool, int64, error) {
 	if !dao.PostMayExist(postID) {
 		return false, 0, gorm.ErrRecordNotFound
 	}
 
-	var post model.Post
-	if err := s.db.Select("id").Where("id = ?", postID).First(&post).Error; err != nil {
+	post, err := dao.GetPostByID(s.db, postID)
+	if err != nil {
+		return false, 0, err
+	}
+	visible, err := canSeePost(s.db, userID, *post)
+	if err != nil {
 		return false, 0, err
 	}
+	if !visible {
+		return false, 0, gorm.ErrRecordNotFound
+	}
 
 	ctx := context.Background()
 	likeSetKey := fmt.Sprintf("like:%d", postID)
 	likeCountKey := fmt.Sprintf("like_count:%d", postID)
 	userIDStr := fmt.Sprintf("%d", userID)
 
-	dao.DelHotPostsCache()
-
 	isMember, err := s.rdb.SIsMember(ctx, likeSetKey, userIDStr).Result()
 	if err != nil {
 		return false, 0, err
@@ -219,37 +296,61 @@ func (s *PostService) ToggleLike(userID, postID uint) (bool, int64, error) {
 		return liked, count, nil
 	}
 
-	dao.DelHotPostsCacheAsync()
+	dao.UpdateHotRankLikes(*post, count)
+	s.recordLike(userID, *post, liked)
 
 	return liked, count, nil
 }
 
-func (s *PostService) ListHotPosts(limit int) ([]model.Post, error) {
-	return dao.GetHotPosts(s.db, limit)
+// hot posts ranked within a window ("1h", "24h" or "7d"); the ranking is shared
+// by everyone, so posts the viewer may not see are filtered out per request
+func (s *PostService) ListHotPosts(viewerID uint, window string, limit int) ([]model.Post, error) {
+	// over-fetch so hidden posts rarely leave the page short
+	posts, err := dao.GetHotPosts(s.db, window, limit*2)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err = visiblePosts(s.db, viewerID, posts)
+	if err != nil {
+		return nil, err
+	}
+	posts, err = hideShadowBanned(s.db, viewerID, posts)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return markBookmarked(s.db, viewerID, posts)
 }
 
-// push the new post to the author's and all followers' inboxes
+// push the new post to the author's and all followers' inboxes; inboxes that
+// do not exist are skipped and pick the post up when rebuilt. Posts of a
+// shadow-banned author only reach the author's own inbox
 func (s *PostService) pushPostInbox(post model.Post) {
 	ctx := context.Background()
 
-	var rels []model.Follow
-	if err := s.db.Where("follow_id = ?", post.UserID).Find(&rels).Error; err != nil {
+	status, err := dao.GetUserStatus(s.db, post.UserID)
+	if err != nil {
+		log.Printf("[warn] status of user %d for fan-out failed: %v\n", post.UserID, err)
 		return
 	}
 
+	var rels []model.Follow
+	if !status.ShadowBanned {
+		if err := s.db.Where("follow_id = ?", post.UserID).Find(&rels).Error; err != nil {
+			return
+		}
+	}
+
 	userIDs := make([]uint, 0, len(rels)+1)
 	userIDs = append(userIDs, post.UserID)
 	for _, r := range rels {
 		userIDs = append(userIDs, r.UserID)
 	}
 
-	score := float64(post.CreatedAt.Unix())
-
-	for _, uid := range userIDs {
-		key := fmt.Sprintf("inbox:%d", uid)
-		_ = s.rdb.ZAdd(ctx, key, redis.Z{
-			Score:  score,
-			Member: post.ID,
-		}).Err()
+	if err := pushToInboxes(ctx, s.rdb, userIDs, post); err != nil {
+		log.Printf("[warn] push post %d to inboxes failed: %v\n", post.ID, err)
 	}
 }
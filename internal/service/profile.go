@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// pinned posts per author
+const MaxPinnedPosts = 3
+
+var (
+	ErrPrivateAccount = errors.New("account is private")
+	ErrNotPostOwner   = errors.New("not the author of the post")
+	ErrTooManyPinned  = errors.New("too many pinned posts")
+)
+
+// one author's posts for their profile, newest first from the author's cached
+// timeline; pinned posts come separately on the first page and are left out of
+// the list. A block either way reports the user as not found
+func (s *PostService) ListUserPosts(viewerID, authorID uint, limit int, cursor uint64) ([]model.Post, []model.Post, uint64, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	if err := s.checkProfileVisible(viewerID, authorID); err != nil {
+		return nil, nil, 0, err
+	}
+
+	var pinned []model.Post
+	if err := s.db.Where("user_id = ? AND pinned_at IS NOT NULL", authorID).Order("pinned_at DESC").Find(&pinned).Error; err != nil {
+		return nil, nil, 0, err
+	}
+	isPinned := make(map[uint]bool, len(pinned))
+	for _, p := range pinned {
+		isPinned[p.ID] = true
+	}
+	if cursor > 0 {
+		pinned = []model.Post{}
+	}
+	pinned, err := markBookmarked(s.db, viewerID, pinned)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	lists, err := dao.AuthorTimelines(s.db, []uint{authorID}, cursor, limit)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	ids := lists[0]
+	if len(ids) == 0 {
+		return pinned, []model.Post{}, 0, nil
+	}
+
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	list := make([]model.Post, 0, len(posts))
+	for _, p := range posts {
+		if !isPinned[p.ID] {
+			list = append(list, p)
+		}
+	}
+
+	list, err = markBookmarked(s.db, viewerID, list)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// the cursor follows the timeline ids, so skipped pinned posts do not stall paging
+	var nextCursor uint64
+	if len(ids) == limit {
+		nextCursor = uint64(ids[len(ids)-1])
+	}
+	return pinned, list, nextCursor, nil
+}
+
+// private accounts are shown to approved followers only; mutes do not hide a profile
+func (s *PostService) checkProfileVisible(viewerID, authorID uint) error {
+	var author model.User
+	if err := s.db.Select("id", "is_private").First(&author, authorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if viewerID == authorID {
+		return nil
+	}
+
+	flags, err := dao.GetRelationFlags(s.db, viewerID, []uint{authorID})
+	if err != nil {
+		return err
+	}
+	if flags.Blocking[0] || flags.BlockedBy[0] {
+		return ErrUserNotFound
+	}
+	if author.IsPrivate && !flags.Following[0] {
+		return ErrPrivateAccount
+	}
+	return nil
+}
+
+// pins one of the user's own posts to the top of their profile
+func (s *PostService) PinPost(userID, postID uint) error {
+	post, err := s.ownPost(userID, postID)
+	if err != nil {
+		return err
+	}
+	if post.PinnedAt != nil {
+		return nil
+	}
+
+	dao.DelPostCache(postID)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// concurrent pins by the same user queue on the user row, so the count holds
+		if _, err := lockUser(tx, userID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.Post{}).Where("user_id = ? AND pinned_at IS NOT NULL", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxPinnedPosts {
+			return ErrTooManyPinned
+		}
+		return tx.Model(&model.Post{}).Where("id = ?", postID).Update("pinned_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+	dao.DelPostCacheAsync(postID)
+	return nil
+}
+
+// puts a pinned post back into the chronological list
+func (s *PostService) UnpinPost(userID, postID uint) error {
+	if _, err := s.ownPost(userID, postID); err != nil {
+		return err
+	}
+
+	dao.DelPostCache(postID)
+	if err := s.db.Model(&model.Post{}).Where("id = ?", postID).Update("pinned_at", nil).Error; err != nil {
+		return err
+	}
+	dao.DelPostCacheAsync(postID)
+	return nil
+}
+
+// reads the post from MySQL, as the cached copy may carry a stale pin
+func (s *PostService) ownPost(userID, postID uint) (*model.Post, error) {
+	var post model.Post
+	if err := s.db.First(&post, postID).Error; err != nil {
+		return nil, err
+	}
+	if post.UserID != userID {
+		return nil, ErrNotPostOwner
+	}
+	return &post, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"minifeed/internal/config"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// compares the pull feed's k-way merge over cached author timelines with the
+// single IN query it replaced. needs MySQL and Redis to seed into:
+//
+//	MYSQL_DSN=... REDIS_ADDR=localhost:6379 go test ./internal/service -run '^$' -bench PullFeed
+func BenchmarkPullFeed(b *testing.B) {
+	dsn, addr := os.Getenv("MYSQL_DSN"), os.Getenv("REDIS_ADDR")
+	if dsn == "" || addr == "" {
+		b.Skip("MYSQL_DSN and REDIS_ADDR are required")
+	}
+
+	db := config.InitDB(dsn).Session(&gorm.Session{Logger: logger.Discard})
+	svc := NewPostService(db, config.InitRedis(addr))
+
+	for _, authors := range []int{200, 2000} {
+		readerID := seedPullFeed(b, db, authors, 5)
+
+		b.Run(fmt.Sprintf("authors=%d/merge", authors), func(b *testing.B) {
+			benchPullFeed(b, readerID, svc.ListFollowFeed)
+		})
+		b.Run(fmt.Sprintf("authors=%d/in_query", authors), func(b *testing.B) {
+			benchPullFeed(b, readerID, svc.listFollowFeedByQuery)
+		})
+	}
+}
+
+func benchPullFeed(b *testing.B, readerID uint, list func(uint, int, uint64) ([]model.Post, uint64, error)) {
+	// the first read starts loading the author timelines into Redis in the background
+	if _, _, err := list(readerID, 20, 0); err != nil {
+		b.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := list(readerID, 20, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// one reader following authors authors with postsPerAuthor posts each, removed after the benchmark
+func seedPullFeed(b *testing.B, db *gorm.DB, authors, postsPerAuthor int) uint {
+	b.Helper()
+	prefix := fmt.Sprintf("pfb%d_%d_", time.Now().Unix()%100000, authors)
+
+	users := make([]model.User, authors+1)
+	for i := range users {
+		users[i] = model.User{Username: fmt.Sprintf("%s%d", prefix, i), Password: "-", Role: model.RoleUser}
+	}
+	if err := db.CreateInBatches(&users, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+	reader, authorRows := users[0], users[1:]
+
+	authorIDs := make([]uint, len(authorRows))
+	follows := make([]model.Follow, len(authorRows))
+	posts := make([]model.Post, 0, authors*postsPerAuthor)
+	for i, a := range authorRows {
+		authorIDs[i] = a.ID
+		follows[i] = model.Follow{UserID: reader.ID, FollowID: a.ID}
+		for j := 0; j < postsPerAuthor; j++ {
+			posts = append(posts, model.Post{UserID: a.ID, Content: fmt.Sprintf("bench post %d of %s", j, a.Username)})
+		}
+	}
+	if err := db.CreateInBatches(&follows, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+	if err := db.CreateInBatches(&posts, 1000).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	b.Cleanup(func() {
+		db.Unscoped().Where("user_id IN ?", authorIDs).Delete(&model.Post{})
+		db.Where("user_id = ?", reader.ID).Delete(&model.Follow{})
+		db.Where("username LIKE ?", prefix+"%").Delete(&model.User{})
+	})
+	return reader.ID
+}
+
+// the pull feed as it was before the merge: one IN query on posts
+func (s *PostService) listFollowFeedByQuery(userID uint, limit int, cursor uint64) ([]model.Post, uint64, error) {
+	ids, err := s.followFeedAuthors(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ids) == 0 {
+		return []model.Post{}, 0, nil
+	}
+
+	var posts []model.Post
+	query := s.db.Where("user_id IN ?", ids).Order("id DESC").Limit(limit)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	if err := query.Find(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var nextCursor uint64
+	if len(posts) > 0 {
+		nextCursor = uint64(posts[len(posts)-1].ID)
+	}
+
+	posts, err = markBookmarked(s.db, userID, posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, nextCursor, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// candidates taken from each source before ranking
+	rankedPerSource = 200
+	// newest posts read from each followed author's timeline
+	rankedPerAuthor = 20
+	rankedHotTop    = 50
+	// ranked ids kept in a snapshot; paging stops there
+	rankedSnapshotSize = 300
+)
+
+// personalized home feed: the first page ranks candidates from the inbox, followed
+// authors and the hot list and snapshots the result; the cursor
+// "<snapshot>_<offset>" pages through that snapshot
+func (s *PostService) ListRankedFeed(userID uint, limit int, cursor string) ([]model.Post, string, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	snapshotID, offset := "", 0
+	if cursor != "" {
+		id, off, ok := strings.Cut(cursor, "_")
+		n, err := strconv.Atoi(off)
+		if !ok || id == "" || err != nil || n < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		snapshotID, offset = id, n
+	} else {
+		id, err := s.snapshotRanking(userID)
+		if err != nil {
+			return nil, "", err
+		}
+		snapshotID = id
+	}
+
+	ids, more, err := dao.GetRankedSnapshotPage(userID, snapshotID, offset, limit)
+	if errors.Is(err, dao.ErrSnapshotExpired) && cursor == "" {
+		return []model.Post{}, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	// blocks, a lost follow or a shadow ban since the snapshot was taken still apply
+	posts, err = visiblePosts(s.db, userID, posts)
+	if err != nil {
+		return nil, "", err
+	}
+	posts, err = hideShadowBanned(s.db, userID, posts)
+	if err != nil {
+		return nil, "", err
+	}
+	posts, err = markBookmarked(s.db, userID, posts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if more {
+		nextCursor = fmt.Sprintf("%s_%d", snapshotID, offset+len(ids))
+	}
+	return posts, nextCursor, nil
+}
+
+// gathers, scores and stores the viewer's candidates, returning the snapshot id
+func (s *PostService) snapshotRanking(userID uint) (string, error) {
+	ids, fromHot, err := s.rankedCandidates(userID)
+	if err != nil {
+		return "", err
+	}
+
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return "", err
+	}
+	posts, err = visiblePosts(s.db, userID, posts)
+	if err != nil {
+		return "", err
+	}
+	posts, err = hideShadowBanned(s.db, userID, posts)
+	if err != nil {
+		return "", err
+	}
+
+	affinity, err := dao.GetAuthorAffinity(userID)
+	if err != nil {
+		affinity = map[uint]float64{}
+	}
+	likes := dao.LiveLikeCounts(posts)
+
+	now := time.Now()
+	r := currentRanker()
+	candidates := make([]RankCandidate, len(posts))
+	scores := make(map[uint]float64, len(posts))
+	for i, p := range posts {
+		candidates[i] = RankCandidate{
+			PostID:    p.ID,
+			AuthorID:  p.UserID,
+			Likes:     likes[p.ID],
+			Affinity:  affinity[p.UserID],
+			FromHot:   fromHot[p.ID],
+			CreatedAt: p.CreatedAt,
+		}
+		scores[p.ID] = r.Score(candidates[i], now)
+	}
+
+	// ties fall back to the newer post so the order is deterministic
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := scores[candidates[i].PostID], scores[candidates[j].PostID]
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i].PostID > candidates[j].PostID
+	})
+	if len(candidates) > rankedSnapshotSize {
+		candidates = candidates[:rankedSnapshotSize]
+	}
+
+	ranked := make([]uint, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.PostID
+	}
+	snapshotID, err := dao.SaveRankedSnapshot(userID, ranked)
+	if err != nil {
+		return "", err
+	}
+
+	go s.logImpressions(userID, snapshotID, candidates, scores, now)
+
+	return snapshotID, nil
+}
+
+// candidate ids from the three sources; fromHot marks those only the hot list supplied
+func (s *PostService) rankedCandidates(userID uint) ([]uint, map[uint]bool, error) {
+	ctx := context.Background()
+	seen := make(map[uint]bool)
+	var ids []uint
+	add := func(id uint) bool {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		return true
+	}
+
+	// the push inbox
+	if err := s.ensureInbox(ctx, userID); err != nil {
+		return nil, nil, err
+	}
+	members, err := s.rdb.ZRevRangeByScore(ctx, inboxKey(userID), &redis.ZRangeBy{
+		Max:   "+inf",
+		Min:   "(0",
+		Count: rankedPerSource,
+	}).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, m := range members {
+		if id64, err := strconv.ParseUint(m, 10, 64); err == nil && id64 > 0 {
+			add(uint(id64))
+		}
+	}
+
+	// followed authors' timelines, which also cover authors followed before the inbox existed
+	authors, err := s.followFeedAuthors(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(authors) > 0 {
+		lists, err := dao.AuthorTimelines(s.db, authors, 0, rankedPerAuthor)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range mergeNewest(lists, rankedPerSource) {
+			add(id)
+		}
+	}
+
+	// the hot list
+	fromHot := make(map[uint]bool)
+	hot, err := dao.GetHotPosts(s.db, dao.DefaultHotWindow, rankedHotTop)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range hot {
+		if add(p.ID) {
+			fromHot[p.ID] = true
+		}
+	}
+
+	return ids, fromHot, nil
+}
+
+// keeps the features each candidate was scored on for offline evaluation; a
+// reader's snapshots are logged at most once per window, as each writes up to
+// rankedSnapshotSize rows
+func (s *PostService) logImpressions(userID uint, snapshotID string, candidates []RankCandidate, scores map[uint]float64, now time.Time) {
+	if len(candidates) == 0 {
+		return
+	}
+	claimed, err := dao.ClaimImpressionLog(userID)
+	if err != nil {
+		log.Printf("[warn] log impressions of snapshot %s failed: %v\n", snapshotID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	rows := make([]model.FeedImpression, len(candidates))
+	for i, c := range candidates {
+		rows[i] = model.FeedImpression{
+			UserID:     userID,
+			SnapshotID: snapshotID,
+			PostID:     c.PostID,
+			AuthorID:   c.AuthorID,
+			Position:   i,
+			AgeHours:   now.Sub(c.CreatedAt).Hours(),
+			Likes:      c.Likes,
+			Affinity:   c.Affinity,
+			FromHot:    c.FromHot,
+			Score:      scores[c.PostID],
+			CreatedAt:  now,
+		}
+	}
+	if err := s.db.CreateInBatches(rows, 100).Error; err != nil {
+		log.Printf("[warn] log impressions of snapshot %s failed: %v\n", snapshotID, err)
+	}
+}
+
+// records a like or unlike and feeds the viewer's author affinity
+func (s *PostService) recordLike(userID uint, post model.Post, liked bool) {
+	kind, delta := "like", int64(1)
+	if !liked {
+		kind, delta = "unlike", -1
+	}
+	dao.IncrAuthorAffinity(userID, post.UserID, delta)
+
+	go func() {
+		ev := model.Interaction{UserID: userID, PostID: post.ID, AuthorID: post.UserID, Kind: kind}
+		if err := s.db.Create(&ev).Error; err != nil {
+			log.Printf("[warn] log %s of post %d failed: %v\n", kind, post.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// everything a ranker may look at when scoring a home-feed candidate
+type RankCandidate struct {
+	PostID    uint
+	AuthorID  uint
+	Likes     int64
+	Affinity  float64 // log-damped count of the viewer's likes on this author
+	FromHot   bool    // came from the hot list rather than the viewer's follows
+	CreatedAt time.Time
+}
+
+// pluggable scoring function for the ranked home feed
+type Ranker interface {
+	Score(c RankCandidate, now time.Time) float64
+}
+
+// recency decays exponentially with the given half-life; engagement and
+// affinity lift the score multiplicatively
+type WeightedRanker struct {
+	HalfLife       time.Duration
+	LikeWeight     float64
+	AffinityWeight float64
+	HotPenalty     float64 // multiplier for posts from outside the viewer's follows
+}
+
+func (r WeightedRanker) Score(c RankCandidate, now time.Time) float64 {
+	age := now.Sub(c.CreatedAt)
+	if age < 0 {
+		age = 0
+	}
+	recency := math.Exp2(-age.Hours() / r.HalfLife.Hours())
+
+	score := recency * (1 + r.LikeWeight*math.Log1p(float64(c.Likes))) * (1 + r.AffinityWeight*c.Affinity)
+	if c.FromHot {
+		score *= r.HotPenalty
+	}
+	return score
+}
+
+// newest first, the baseline the ranked feed is compared against
+type ChronoRanker struct{}
+
+func (ChronoRanker) Score(c RankCandidate, now time.Time) float64 {
+	return float64(c.CreatedAt.UnixNano())
+}
+
+var (
+	rankerMu sync.RWMutex
+	ranker   Ranker = DefaultRanker()
+)
+
+func DefaultRanker() WeightedRanker {
+	return WeightedRanker{HalfLife: 6 * time.Hour, LikeWeight: 0.5, AffinityWeight: 1, HotPenalty: 0.6}
+}
+
+// swaps the ranking function, e.g. to run an experiment
+func SetRanker(r Ranker) {
+	if r == nil {
+		return
+	}
+	rankerMu.Lock()
+	ranker = r
+	rankerMu.Unlock()
+}
+
+func currentRanker() Ranker {
+	rankerMu.RLock()
+	defer rankerMu.RUnlock()
+	return ranker
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+)
+
+// a post search as the API receives it; Q may hold "phrases", prefix* words and a #hashtag
+type PostSearch struct {
+	Q        string
+	AuthorID uint
+	Hashtag  string
+	Since    time.Time
+	Until    time.Time
+	Sort     string // "relevance" (default) or "recent"
+}
+
+// full-text search over posts; hits the viewer may not see are dropped after the
+// index answered, so a page can come back shorter than limit while next_cursor
+// still points further
+func (s *PostService) SearchPosts(viewerID uint, req PostSearch, limit int, cursor string) ([]model.Post, string, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+
+	q := dao.ParsePostQuery(req.Q)
+	q.AuthorID = req.AuthorID
+	if tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(req.Hashtag), "#")); tag != "" {
+		q.Hashtag = tag
+	}
+	q.Since, q.Until = req.Since, req.Until
+	q.Sort = req.Sort
+
+	ids, next, err := dao.SearchPosts(s.db, q, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(ids) == 0 {
+		return []model.Post{}, next, nil
+	}
+
+	posts, err := dao.GetPostsByIDs(s.db, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	posts, err = filterPosts(s.db, viewerID, posts, false)
+	if err != nil {
+		return nil, "", err
+	}
+	posts, err = hideShadowBanned(s.db, viewerID, posts)
+	if err != nil {
+		return nil, "", err
+	}
+	posts, err = markBookmarked(s.db, viewerID, posts)
+	if err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+)
+
+const (
+	SuggestionFollowedByFollowings = "followed_by_followings"
+	SuggestionPopular              = "popular"
+)
+
+// an account to follow and why it is suggested
+type Suggestion struct {
+	User        model.User `json:"user"`
+	MutualCount int64      `json:"mutual_count"` // my followings who follow this account
+	Reason      string     `json:"reason"`
+}
+
+// "who to follow": friends-of-friends first, popular accounts fill the rest.
+// the precomputed lists may be stale, so follows and blocks are re-checked here
+func (s *FollowService) Suggestions(userID uint, limit int) ([]Suggestion, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	fof, err := dao.GetSuggestions(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	popular, err := dao.PopularUsers(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[uint]bool{userID: true}
+	var ids []uint
+	mutuals := make(map[uint]int64)
+	for _, c := range fof {
+		if !seen[c.UserID] {
+			seen[c.UserID] = true
+			ids = append(ids, c.UserID)
+			mutuals[c.UserID] = c.Score
+		}
+	}
+	for _, c := range popular {
+		if !seen[c.UserID] {
+			seen[c.UserID] = true
+			ids = append(ids, c.UserID)
+		}
+	}
+	if len(ids) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	flags, err := dao.GetRelationFlags(s.db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	var pending []uint
+	if err := s.db.Model(&model.FollowRequest{}).
+		Where("user_id = ? AND target_id IN ?", userID, ids).
+		Pluck("target_id", &pending).Error; err != nil {
+		return nil, err
+	}
+	requested := make(map[uint]bool, len(pending))
+	for _, id := range pending {
+		requested[id] = true
+	}
+
+	picked := make([]uint, 0, limit)
+	for i, id := range ids {
+		if flags.Following[i] || flags.Blocking[i] || flags.BlockedBy[i] || flags.Muting[i] || requested[id] {
+			continue
+		}
+		picked = append(picked, id)
+		if len(picked) == limit {
+			break
+		}
+	}
+	if len(picked) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	var users []model.User
+	if err := s.db.Where("id IN ?", picked).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	list := make([]Suggestion, 0, len(picked))
+	for _, id := range picked {
+		u, ok := byID[id]
+		if !ok {
+			continue
+		}
+		reason := SuggestionPopular
+		if mutuals[id] > 0 {
+			reason = SuggestionFollowedByFollowings
+		}
+		list = append(list, Suggestion{User: u, MutualCount: mutuals[id], Reason: reason})
+	}
+	return list, nil
+}
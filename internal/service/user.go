@@ -2,10 +2,18 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"minifeed/internal/dao"
 	"minifeed/internal/model"
 	jwtUtil "minifeed/pkg/jwt"
 
+	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -14,6 +22,9 @@ var (
 	ErrUserExists    = errors.New("username already exists")
 	ErrUserNotFound  = errors.New("user not found")
 	ErrWrongPassword = errors.New("wrong password")
+	ErrUserSuspended = errors.New("account suspended")
+
+	ErrInvalidProfile = errors.New("invalid profile")
 )
 
 type UserService struct {
@@ -26,11 +37,11 @@ func NewUserService(db *gorm.DB) *UserService {
 
 // Register
 func (s *UserService) Register(username, password string) (*model.User, error) {
-	var count int64
-	if err := s.db.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+	available, err := s.UsernameAvailable(username)
+	if err != nil {
 		return nil, err
 	}
-	if count > 0 {
+	if !available {
 		return nil, ErrUserExists
 	}
 
@@ -42,14 +53,40 @@ func (s *UserService) Register(username, password string) (*model.User, error) {
 	u := &model.User{
 		Username: username,
 		Password: string(hashed),
+		Role:     model.RoleUser,
 	}
 	if err := s.db.Create(u).Error; err != nil {
+		// the unique index settles a race the availability check cannot
+		if isDuplicateKey(err) {
+			return nil, ErrUserExists
+		}
 		return nil, err
 	}
+
+	dao.AddUserToBloom(u)
+
 	return u, nil
 
 }
 
+// a Bloom miss that rules the name out means it was never taken; anything else needs MySQL
+func (s *UserService) UsernameAvailable(username string) (bool, error) {
+	if !dao.UsernameMayExist(username) {
+		return true, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
 // login
 func (s *UserService) Login(username, password string) (*model.User, string, error) {
 	var u model.User
@@ -63,8 +100,11 @@ func (s *UserService) Login(username, password string) (*model.User, string, err
 	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
 		return nil, "", ErrWrongPassword
 	}
+	if u.SuspendedAt != nil {
+		return nil, "", ErrUserSuspended
+	}
 
-	token, err := jwtUtil.GenerateToken(u.ID)
+	token, err := jwtUtil.GenerateToken(u.ID, u.Role)
 	if err != nil {
 		return nil, "", err
 	}
@@ -73,16 +113,163 @@ func (s *UserService) Login(username, password string) (*model.User, string, err
 
 }
 
-// search by username
-func (s *UserService) SearchByUsername(keyword string, limit int) ([]model.User, error) {
+// search by username prefix; users with a block in either direction are left out
+func (s *UserService) SearchByUsername(viewerID uint, keyword string, limit int) ([]model.User, error) {
 	if limit <= 0 || limit > 20 {
 		limit = 20
 	}
 
 	var users []model.User
-	if err := s.db.Limit(limit).Find(&users).Error; err != nil {
+	if err := s.db.Where("username LIKE ?", escapeLike(keyword)+"%").Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	ids := make([]uint, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	blocked, err := dao.BlockedEitherWay(s.db, viewerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]model.User, 0, len(users))
+	for i, u := range users {
+		if !blocked[i] {
+			visible = append(visible, u)
+		}
+	}
+	return visible, nil
+
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// makes the LIKE metacharacters in s match literally; MySQL escapes with a backslash by default
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// limits of the editable profile fields, in characters
+const (
+	MaxDisplayNameLen = 50
+	MaxBioLen         = 160
+	MaxLocationLen    = 30
+	MaxProfileURLLen  = 255
+)
+
+// a user's public profile with counts and, for someone else's profile, how the viewer relates to them
+type Profile struct {
+	model.User
+	PostCount  int64 `json:"post_count"`
+	Following  bool  `json:"following"`
+	FollowedBy bool  `json:"followed_by"`
+
+	// only on the viewer's own profile
+	Role        string     `json:"role,omitempty"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+}
+
+// fields a PATCH /api/me may change; nil leaves a field as it is, "" clears it
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Website     *string `json:"website"`
+	Location    *string `json:"location"`
+}
+
+// the profile of userID as viewerID sees it; a block either way reports the user as not found
+func (s *UserService) GetProfile(viewerID, userID uint) (*Profile, error) {
+	var u model.User
+	if err := s.db.First(&u, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
-	return users, nil
 
+	p := &Profile{User: u}
+	if viewerID == userID {
+		p.Role = u.Role
+		p.SuspendedAt = u.SuspendedAt
+	} else {
+		flags, err := dao.GetRelationFlags(s.db, viewerID, []uint{userID})
+		if err != nil {
+			return nil, err
+		}
+		if flags.Blocking[0] || flags.BlockedBy[0] {
+			return nil, ErrUserNotFound
+		}
+		p.Following = flags.Following[0]
+		p.FollowedBy = flags.FollowedBy[0]
+	}
+
+	if err := s.db.Model(&model.Post{}).Where("user_id = ?", userID).Count(&p.PostCount).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validates and applies a profile edit, returning the updated profile
+func (s *UserService) UpdateProfile(userID uint, req ProfileUpdate) (*Profile, error) {
+	updates := make(map[string]interface{})
+
+	text := func(col, name string, v *string, max int) error {
+		if v == nil {
+			return nil
+		}
+		val := strings.TrimSpace(*v)
+		if utf8.RuneCountInString(val) > max {
+			return fmt.Errorf("%w: %s longer than %d characters", ErrInvalidProfile, name, max)
+		}
+		// bios may span lines; nothing else may carry control characters
+		for _, r := range val {
+			if unicode.IsControl(r) && !(col == "bio" && r == '\n') {
+				return fmt.Errorf("%w: %s contains control characters", ErrInvalidProfile, name)
+			}
+		}
+		updates[col] = val
+		return nil
+	}
+	link := func(col, name string, v *string) error {
+		if v == nil {
+			return nil
+		}
+		val := strings.TrimSpace(*v)
+		if val != "" {
+			if len(val) > MaxProfileURLLen {
+				return fmt.Errorf("%w: %s longer than %d characters", ErrInvalidProfile, name, MaxProfileURLLen)
+			}
+			u, err := url.Parse(val)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: %s must be an http(s) url", ErrInvalidProfile, name)
+			}
+		}
+		updates[col] = val
+		return nil
+	}
+
+	for _, err := range []error{
+		text("display_name", "display_name", req.DisplayName, MaxDisplayNameLen),
+		text("bio", "bio", req.Bio, MaxBioLen),
+		text("location", "location", req.Location, MaxLocationLen),
+		link("avatar_url", "avatar_url", req.AvatarURL),
+		link("website", "website", req.Website),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(updates) > 0 {
+		res := s.db.Model(&model.User{}).Where("id = ?", userID).Updates(updates)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+	}
+	return s.GetProfile(userID, userID)
 }
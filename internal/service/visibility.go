@@ -0,0 +1,107 @@
+package service
+
+import (
+	"minifeed/internal/dao"
+	"minifeed/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// drops the posts viewerID may not see in a feed: posts of private accounts are only
+// shown to their approved followers, a block in either direction hides everything,
+// and muted authors are left out of the viewer's feeds; viewerID 0 is anonymous
+func visiblePosts(db *gorm.DB, viewerID uint, posts []model.Post) ([]model.Post, error) {
+	return filterPosts(db, viewerID, posts, true)
+}
+
+// whether viewerID may see (and so interact with) one post; muting does not hide it
+func canSeePost(db *gorm.DB, viewerID uint, post model.Post) (bool, error) {
+	visible, err := filterPosts(db, viewerID, []model.Post{post}, false)
+	if err != nil {
+		return false, err
+	}
+	return len(visible) == 1, nil
+}
+
+// drops posts of shadow-banned authors from every feed but their own: public, hot,
+// search, the pull and ranked feeds and lists; the authors still see their own posts
+func hideShadowBanned(db *gorm.DB, viewerID uint, posts []model.Post) ([]model.Post, error) {
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	authors := make([]uint, 0, len(posts))
+	for _, p := range posts {
+		authors = append(authors, p.UserID)
+	}
+	banned, err := dao.ShadowBannedAmong(db, authors)
+	if err != nil {
+		return nil, err
+	}
+	if len(banned) == 0 {
+		return posts, nil
+	}
+
+	kept := make([]model.Post, 0, len(posts))
+	for _, p := range posts {
+		if !banned[p.UserID] || p.UserID == viewerID {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+func filterPosts(db *gorm.DB, viewerID uint, posts []model.Post, hideMuted bool) ([]model.Post, error) {
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	authorSet := make(map[uint]struct{}, len(posts))
+	authors := make([]uint, 0, len(posts))
+	for _, p := range posts {
+		if p.UserID == viewerID {
+			continue
+		}
+		if _, ok := authorSet[p.UserID]; !ok {
+			authorSet[p.UserID] = struct{}{}
+			authors = append(authors, p.UserID)
+		}
+	}
+	if len(authors) == 0 {
+		return posts, nil
+	}
+
+	var private []uint
+	if err := db.Model(&model.User{}).Where("id IN ? AND is_private = ?", authors, true).Pluck("id", &private).Error; err != nil {
+		return nil, err
+	}
+	isPrivate := make(map[uint]bool, len(private))
+	for _, id := range private {
+		isPrivate[id] = true
+	}
+
+	hidden := make(map[uint]bool, len(authors))
+	if viewerID == 0 {
+		for _, id := range private {
+			hidden[id] = true
+		}
+	} else {
+		flags, err := dao.GetRelationFlags(db, viewerID, authors)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range authors {
+			hidden[id] = (isPrivate[id] && !flags.Following[i]) ||
+				flags.Blocking[i] || flags.BlockedBy[i] ||
+				(hideMuted && flags.Muting[i])
+		}
+	}
+
+	visible := make([]model.Post, 0, len(posts))
+	for _, p := range posts {
+		if !hidden[p.UserID] {
+			visible = append(visible, p)
+		}
+	}
+	return visible, nil
+}
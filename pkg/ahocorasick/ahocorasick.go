@@ -0,0 +1,200 @@
+// Package ahocorasick finds every occurrence of a fixed set of terms in a text
+// in one pass, however many terms there are.
+//
+// By default a term matches anywhere, also inside a longer word: "ass" is found
+// in "class". A matcher built with NewWholeWord only reports terms standing as
+// words of their own.
+package ahocorasick
+
+import (
+	"strings"
+	"unicode"
+)
+
+type node struct {
+	next map[rune]int
+	fail int
+	// index of the term ending here, -1 if none
+	term int
+	// nearest node on the fail chain that ends a term, -1 if none
+	output int
+}
+
+// Matcher is immutable once built and safe for concurrent use.
+// Matching is case-insensitive, folding case rune by rune.
+type Matcher struct {
+	nodes []node
+	terms []string
+	// length of each term in runes
+	lens      []int
+	wholeWord bool
+}
+
+// New builds a matcher for terms; empty and duplicate terms are ignored
+func New(terms []string) *Matcher {
+	m := &Matcher{nodes: []node{{next: map[rune]int{}, term: -1, output: -1}}}
+
+	seen := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		t = foldString(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		m.insert(t)
+	}
+	m.link()
+	return m
+}
+
+// NewWholeWord is like New, but a term only matches where it is not joined to
+// letters or digits on either side. Scripts written without spaces between
+// words (Han, kana, Thai) have no such boundaries, so there terms match anywhere.
+func NewWholeWord(terms []string) *Matcher {
+	m := New(terms)
+	m.wholeWord = true
+	return m
+}
+
+// maps every case variant of a rune to the same one, e.g. Σ, σ and ς to σ
+func fold(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+func foldString(s string) string {
+	return strings.Map(fold, s)
+}
+
+func (m *Matcher) insert(term string) {
+	cur := 0
+	n := 0
+	for _, r := range term {
+		n++
+		nxt, ok := m.nodes[cur].next[r]
+		if !ok {
+			m.nodes = append(m.nodes, node{next: map[rune]int{}, term: -1, output: -1})
+			nxt = len(m.nodes) - 1
+			m.nodes[cur].next[r] = nxt
+		}
+		cur = nxt
+	}
+	m.nodes[cur].term = len(m.terms)
+	m.terms = append(m.terms, term)
+	m.lens = append(m.lens, n)
+}
+
+// breadth-first, so a node's fail target is always linked before the node itself
+func (m *Matcher) link() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f != 0 && !m.has(f, r) {
+				f = m.nodes[f].fail
+			}
+			if nxt, ok := m.nodes[f].next[r]; ok && nxt != child {
+				m.nodes[child].fail = nxt
+			}
+			fail := m.nodes[child].fail
+			if m.nodes[fail].term >= 0 {
+				m.nodes[child].output = fail
+			} else {
+				m.nodes[child].output = m.nodes[fail].output
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (m *Matcher) has(n int, r rune) bool {
+	_, ok := m.nodes[n].next[r]
+	return ok
+}
+
+func (m *Matcher) step(cur int, r rune) int {
+	for {
+		if nxt, ok := m.nodes[cur].next[r]; ok {
+			return nxt
+		}
+		if cur == 0 {
+			return 0
+		}
+		cur = m.nodes[cur].fail
+	}
+}
+
+// Len is the number of distinct terms
+func (m *Matcher) Len() int {
+	return len(m.terms)
+}
+
+// Find returns the distinct terms occurring in text, case-folded, in order of first occurrence
+func (m *Matcher) Find(text string) []string {
+	var found []string
+	seen := make(map[int]bool)
+	m.scan(text, func(t int) bool {
+		if !seen[t] {
+			seen[t] = true
+			found = append(found, m.terms[t])
+		}
+		return true
+	})
+	return found
+}
+
+// Contains reports whether any term occurs in text
+func (m *Matcher) Contains(text string) bool {
+	found := false
+	m.scan(text, func(int) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// calls hit with the index of each term occurrence, by end position, until it returns false
+func (m *Matcher) scan(text string, hit func(term int) bool) {
+	if len(m.terms) == 0 {
+		return
+	}
+
+	rs := []rune(text)
+	cur := 0
+	for i, r := range rs {
+		cur = m.step(cur, fold(r))
+		for n := cur; n > 0; n = m.nodes[n].output {
+			t := m.nodes[n].term
+			if t < 0 {
+				continue
+			}
+			if m.wholeWord && !standsAlone(rs, i-m.lens[t]+1, i) {
+				continue
+			}
+			if !hit(t) {
+				return
+			}
+		}
+	}
+}
+
+// whether rs[start..end] is not joined to the runes around it
+func standsAlone(rs []rune, start, end int) bool {
+	return (start == 0 || !joined(rs[start-1], rs[start])) &&
+		(end == len(rs)-1 || !joined(rs[end], rs[end+1]))
+}
+
+func joined(a, b rune) bool {
+	return wordRune(a) && wordRune(b)
+}
+
+func wordRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
@@ -0,0 +1,134 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []string
+		text  string
+		want  []string
+	}{
+		{"overlapping", []string{"he", "she", "his", "hers"}, "ushers", []string{"she", "he", "hers"}},
+		{"suffixes of a term", []string{"abcd", "bcd", "cd", "d"}, "xabcd", []string{"abcd", "bcd", "cd", "d"}},
+		{"suffix reached through the fail chain", []string{"abcx", "bc"}, "abcy", []string{"bc"}},
+		{"prefix of a term", []string{"ab", "abc"}, "abx", []string{"ab"}},
+		{"repeated occurrence reported once", []string{"ab"}, "ab ab ab", []string{"ab"}},
+		{"order of first occurrence", []string{"foo", "bar"}, "bar foo bar", []string{"bar", "foo"}},
+		{"no match", []string{"foo"}, "fo o", nil},
+		{"empty text", []string{"foo"}, "", nil},
+		{"ascii case", []string{"Hello"}, "say HELLO", []string{"hello"}},
+		{"greek sigma forms", []string{"λόγος"}, "ΛΌΓΟΣ", []string{"λόγοσ"}},
+		{"kelvin sign", []string{"kelvin"}, "\u212Aelvin", []string{"kelvin"}},
+		{"dotted capital i", []string{"istanbul"}, "İSTANBUL", []string{"istanbul"}},
+		{"capital sharp s", []string{"straße"}, "STRA\u1E9EE", []string{"straße"}},
+		{"han", []string{"违禁", "禁词"}, "这是违禁词", []string{"违禁", "禁词"}},
+		{"substring inside a word", []string{"ass"}, "class", []string{"ass"}},
+		{"empty and blank terms ignored", []string{"", "  ", "x"}, "axb", []string{"x"}},
+		{"duplicate terms ignored", []string{"foo", "FOO", " foo "}, "foo", []string{"foo"}},
+		{"no terms", nil, "anything", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.terms)
+			if got := m.Find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if got := m.Contains(tt.text); got != (len(tt.want) > 0) {
+				t.Errorf("Contains(%q) = %v, want %v", tt.text, got, len(tt.want) > 0)
+			}
+		})
+	}
+}
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		terms []string
+		want  int
+	}{
+		{nil, 0},
+		{[]string{"", " "}, 0},
+		{[]string{"a", "A", "a ", "b"}, 2},
+		{[]string{"Σ", "σ", "ς"}, 1},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.terms).Len(); got != tt.want {
+			t.Errorf("New(%q).Len() = %d, want %d", tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestWholeWord(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []string
+		text  string
+		want  []string
+	}{
+		{"inside a word", []string{"ass"}, "class", nil},
+		{"word prefix", []string{"ass"}, "assess", nil},
+		{"alone", []string{"ass"}, "you ass!", []string{"ass"}},
+		{"whole text", []string{"ass"}, "ASS", []string{"ass"}},
+		{"next to digits", []string{"ass"}, "ass1", nil},
+		{"punctuation", []string{"ass"}, "(ass)", []string{"ass"}},
+		{"later occurrence stands alone", []string{"ass"}, "class ass", []string{"ass"}},
+		{"multi-word term", []string{"bad word"}, "a bad word here", []string{"bad word"}},
+		{"shorter term inside a longer one", []string{"he", "hers"}, "hers", []string{"hers"}},
+		{"han has no boundaries", []string{"违禁"}, "这是违禁词", []string{"违禁"}},
+		{"han next to latin", []string{"ass"}, "你ass好", []string{"ass"}},
+		{"latin term next to a letter in another script", []string{"ass"}, "αass", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewWholeWord(tt.terms)
+			if got := m.Find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if got := m.Contains(tt.text); got != (len(tt.want) > 0) {
+				t.Errorf("Contains(%q) = %v, want %v", tt.text, got, len(tt.want) > 0)
+			}
+		})
+	}
+}
+
+// the automaton agrees with plain substring search on random input over a small alphabet
+func TestFindMatchesNaive(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	word := func(max int) string {
+		var b strings.Builder
+		for n := 1 + rnd.Intn(max); n > 0; n-- {
+			b.WriteByte("abc"[rnd.Intn(3)])
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 500; i++ {
+		terms := make([]string, 1+rnd.Intn(6))
+		for j := range terms {
+			terms[j] = word(4)
+		}
+		text := word(20)
+
+		want := make(map[string]bool)
+		for _, term := range terms {
+			if strings.Contains(text, term) {
+				want[term] = true
+			}
+		}
+		got := make(map[string]bool)
+		for _, term := range New(terms).Find(text) {
+			got[term] = true
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("terms %q in %q: got %v, want %v", terms, text, got, want)
+		}
+	}
+}
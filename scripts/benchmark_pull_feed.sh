@@ -0,0 +1,116 @@
+#!/bin/bash
+# ========================================
+# MiniFeed 拉模式 Feed 压测 (wrk)
+# 与单条 IN 查询的对比见 go test ./internal/service -bench PullFeed
+# 用法: ./scripts/benchmark_pull_feed.sh [作者数] [每个作者的动态数]
+# ========================================
+
+set -e
+
+BASE_URL="http://localhost:8888"
+AUTHORS=${1:-200}
+POSTS_PER_AUTHOR=${2:-5}
+PASSWORD="test123456"
+RUN_ID=$RANDOM
+
+# 颜色定义
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+YELLOW='\033[1;33m'
+CYAN='\033[0;36m'
+MAGENTA='\033[0;35m'
+NC='\033[0m' # No Color
+
+echo -e "${CYAN}=== MiniFeed Pull Feed Benchmark (wrk) ===${NC}\n"
+
+check_wrk() {
+    if ! command -v wrk &> /dev/null; then
+        echo -e "${RED}❌ 未检测到 wrk，请先安装 (参考 scripts/README_BENCHMARK.md)${NC}"
+        exit 1
+    fi
+}
+
+# 注册并登录，输出 token
+login() {
+    local name=$1
+    curl -s -X POST "$BASE_URL/user/register" \
+        -H "Content-Type: application/json" \
+        -d "{\"username\":\"$name\",\"password\":\"$PASSWORD\"}" > /dev/null
+    curl -s -X POST "$BASE_URL/user/login" \
+        -H "Content-Type: application/json" \
+        -d "{\"username\":\"$name\",\"password\":\"$PASSWORD\"}" \
+        | grep -o '"token":"[^"]*' | cut -d'"' -f4
+}
+
+# 准备数据: 1 个读者关注 AUTHORS 个作者，每个作者发 POSTS_PER_AUTHOR 条动态
+seed() {
+    echo -e "${YELLOW}[1/3] 准备数据: ${AUTHORS} 个作者 x ${POSTS_PER_AUTHOR} 条动态...${NC}"
+
+    READER_TOKEN=$(login "pull_reader_$RUN_ID")
+    if [ -z "$READER_TOKEN" ]; then
+        echo -e "${RED}❌ 获取 Token 失败，请检查服务是否启动${NC}"
+        exit 1
+    fi
+
+    for i in $(seq 1 "$AUTHORS"); do
+        local token
+        token=$(login "pull_author_${RUN_ID}_$i")
+        local author_id
+        author_id=$(curl -s "$BASE_URL/api/me" -H "Authorization: Bearer $token" \
+            | grep -o '"data":{"id":[0-9]*' | grep -o '[0-9]*$')
+        if [ -z "$author_id" ]; then
+            echo -e "${RED}❌ 获取作者 $i 的 id 失败${NC}"
+            exit 1
+        fi
+
+        for j in $(seq 1 "$POSTS_PER_AUTHOR"); do
+            curl -s -X POST "$BASE_URL/api/post" \
+                -H "Authorization: Bearer $token" \
+                -H "Content-Type: application/json" \
+                -d "{\"content\":\"bench post $j of author $i\"}" > /dev/null
+        done
+
+        curl -s -X POST "$BASE_URL/api/follow/$author_id" \
+            -H "Authorization: Bearer $READER_TOKEN" > /dev/null
+
+        if [ $((i % 50)) -eq 0 ]; then
+            echo -e "  已准备 $i/$AUTHORS 个作者"
+        fi
+    done
+
+    echo -e "${GREEN}✓ 数据准备完成${NC}"
+}
+
+create_lua_script() {
+    cat > /tmp/wrk_pull.lua << EOF2
+wrk.method = "GET"
+wrk.headers["Authorization"] = "Bearer $READER_TOKEN"
+EOF2
+}
+
+run_tests() {
+    echo -e "\n${YELLOW}[2/3] 预热 (加载作者时间线缓存)...${NC}"
+    curl -s "$BASE_URL/api/feed/pull?limit=20" -H "Authorization: Bearer $READER_TOKEN" > /dev/null
+
+    echo -e "\n${YELLOW}[3/3] 开始压测...${NC}"
+    echo -e "${CYAN}================================================${NC}\n"
+
+    echo -e "${MAGENTA}【k 路归并】4 线程 / 50 并发 / 30 秒${NC}"
+    wrk -t4 -c50 -d30s --latency -s /tmp/wrk_pull.lua \
+        "$BASE_URL/api/feed/pull?limit=20"
+
+    rm -f /tmp/wrk_pull.lua
+}
+
+main() {
+    check_wrk
+    seed
+    create_lua_script
+    run_tests
+
+    echo -e "\n${CYAN}================================================${NC}"
+    echo -e "${GREEN}✓ 压测完成！可调大作者数重复测试${NC}"
+    echo -e "${CYAN}================================================${NC}\n"
+}
+
+main